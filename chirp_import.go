@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/chirpimport"
+	"github.com/hugermuger/chirpy/internal/database"
+	"github.com/hugermuger/chirpy/internal/spam"
+)
+
+const maxImportSize = 10 << 20
+
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// ImportResult reports what happened to each row. Error is set when the
+// archive couldn't be read to the end; the rows before that are imported.
+type ImportResult struct {
+	Imported int              `json:"imported"`
+	Failed   int              `json:"failed"`
+	Errors   []ImportRowError `json:"errors"`
+	Error    string           `json:"error,omitempty"`
+}
+
+func (res *ImportResult) fail(row int, err error) {
+	res.Failed++
+	res.Errors = append(res.Errors, ImportRowError{Row: row, Error: err.Error()})
+}
+
+// importChirps reads an archive in the given format and stores every valid row
+// as a chirp of userID, keeping the original timestamps. Bad rows are recorded
+// in the result and skipped; only a failure to read the archive itself aborts.
+func (cfg *apiConfig) importChirps(ctx context.Context, userID uuid.UUID, archive io.Reader, format string) (ImportResult, error) {
+	result := ImportResult{Errors: []ImportRowError{}}
+
+	author, err := cfg.dbQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return result, fmt.Errorf("couldn't find user: %w", err)
+	}
+
+	store := func(row int, in chirpimport.Row) {
+		err := cfg.importChirp(ctx, author, in)
+		if err != nil {
+			result.fail(row, err)
+			return
+		}
+		result.Imported++
+	}
+
+	switch format {
+	case chirpimport.FormatJSONL:
+		err = chirpimport.ReadJSONLines(archive, store, result.fail)
+	case chirpimport.FormatCSV:
+		err = chirpimport.ReadCSV(archive, store, result.fail)
+	default:
+		err = fmt.Errorf("unsupported import format %q", format)
+	}
+	return result, err
+}
+
+func (cfg *apiConfig) importChirp(ctx context.Context, author database.User, in chirpimport.Row) error {
+	if strings.TrimSpace(in.Body) == "" {
+		return errors.New("body is required")
+	}
+
+	moderated, err := cfg.validateChirp(in.Body, author)
+	if err != nil {
+		return err
+	}
+
+	createdAt, err := chirpimport.ParseCreatedAt(in.CreatedAt, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// Imports go through the same duplicate and spam checks as addChirp, so
+	// they can't be used to get around them.
+	decision, err := cfg.scoreChirp(ctx, author, moderated.Text)
+	if err != nil {
+		return errors.New("couldn't score chirp")
+	}
+	if decision.Verdict == spam.Limit {
+		return errors.New("posting too fast, try again later")
+	}
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New("couldn't store chirp")
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	duplicate, err := cfg.isDuplicateChirp(ctx, q, author.ID, moderated.Text)
+	if err != nil {
+		return errors.New("couldn't check for duplicate chirps")
+	}
+	if duplicate {
+		return errors.New("this chirp was already posted recently")
+	}
+
+	status := "published"
+	if decision.Verdict == spam.Queue {
+		status = "pending"
+	}
+	chirp, err := q.ImportChirp(ctx, database.ImportChirpParams{
+		CreatedAt: createdAt.UTC(),
+		Body:      moderated.Text,
+		UserID:    author.ID,
+		Status:    status,
+	})
+	if err != nil {
+		return errors.New("couldn't store chirp")
+	}
+
+	if decision.Verdict == spam.Queue {
+		err = holdForReview(ctx, q, chirp.ID, decision)
+		if err != nil {
+			return errors.New("couldn't queue chirp for review")
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("couldn't store chirp")
+	}
+
+	cfg.recordFlags(ctx, chirp.ID, moderated)
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hugermuger/chirpy/internal/chirpimport"
+	"github.com/hugermuger/chirpy/internal/database"
+)
+
+// runCommand handles the command line subcommands. It returns false if args
+// don't name one, in which case the server is started.
+func runCommand(cfg *apiConfig, args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "import":
+		err := importCommand(cfg, args[1:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "import: %s\n", err)
+			os.Exit(1)
+		}
+		return true
+	case "moderator":
+		err := moderatorCommand(cfg, args[1:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "moderator: %s\n", err)
+			os.Exit(1)
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// importCommand loads an archive for an existing user:
+//
+//	chirpy import -email user@example.com [-format csv] chirps.jsonl
+func importCommand(cfg *apiConfig, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	email := flags.String("email", "", "email of the user the chirps belong to")
+	format := flags.String("format", "", "archive format: jsonl or csv (default: from file extension)")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *email == "" || flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("an -email and exactly one archive file are required")
+	}
+
+	path := flags.Arg(0)
+	archiveFormat := chirpimport.Format(*format, path)
+	if archiveFormat != chirpimport.FormatJSONL && archiveFormat != chirpimport.FormatCSV {
+		return fmt.Errorf("can't tell the format of %s, use -format", path)
+	}
+
+	ctx := context.Background()
+	user, err := cfg.dbQueries.GetUser(ctx, *email)
+	if err != nil {
+		return fmt.Errorf("couldn't find user %s: %w", *email, err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	result, importErr := cfg.importChirps(ctx, user.ID, file, archiveFormat)
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(result)
+	if importErr != nil {
+		return importErr
+	}
+	return err
+}
+
+// moderatorCommand grants or revokes moderator rights:
+//
+//	chirpy moderator [-revoke] user@example.com
+func moderatorCommand(cfg *apiConfig, args []string) error {
+	flags := flag.NewFlagSet("moderator", flag.ContinueOnError)
+	revoke := flags.Bool("revoke", false, "take moderator rights away instead")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("exactly one email is required")
+	}
+
+	ctx := context.Background()
+	user, err := cfg.dbQueries.GetUser(ctx, flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("couldn't find user %s: %w", flags.Arg(0), err)
+	}
+
+	return cfg.dbQueries.SetUserModerator(ctx, database.SetUserModeratorParams{
+		IsModerator: !*revoke,
+		ID:          user.ID,
+	})
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/database"
+)
+
+type accountExport struct {
+	GeneratedAt  time.Time          `json:"generated_at"`
+	Profile      exportProfile      `json:"profile"`
+	Subscription exportSubscription `json:"subscription"`
+	Chirps       []Chirp            `json:"chirps"`
+	Sessions     []exportSession    `json:"sessions"`
+}
+
+type exportProfile struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Email     string    `json:"email"`
+}
+
+// exportSubscription only reflects the current plan; Polka webhooks don't
+// leave a history behind beyond the is_chirpy_red flag.
+type exportSubscription struct {
+	Plan        string `json:"plan"`
+	IsChirpyRed bool   `json:"is_chirpy_red"`
+}
+
+// exportSession describes a refresh token without the token itself.
+type exportSession struct {
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+var exportHTML = template.Must(template.New("export").Parse(`<html>
+  <head>
+    <meta charset="utf-8">
+    <title>Chirpy account export</title>
+  </head>
+  <body>
+    <h1>Chirpy account export</h1>
+    <p>Generated at {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}}</p>
+
+    <h2>Profile</h2>
+    <ul>
+      <li>ID: {{.Profile.ID}}</li>
+      <li>Email: {{.Profile.Email}}</li>
+      <li>Member since: {{.Profile.CreatedAt.Format "2006-01-02"}}</li>
+      <li>Plan: {{.Subscription.Plan}}</li>
+    </ul>
+
+    <h2>Chirps ({{len .Chirps}})</h2>
+    <ul>
+    {{- range .Chirps}}
+      <li><time>{{.CreatedAt.Format "2006-01-02 15:04"}}</time> {{.Body}}</li>
+    {{- end}}
+    </ul>
+
+    <h2>Sessions ({{len .Sessions}})</h2>
+    <ul>
+    {{- range .Sessions}}
+      <li>Started {{.CreatedAt.Format "2006-01-02 15:04"}}, expires {{.ExpiresAt.Format "2006-01-02 15:04"}}{{if .RevokedAt}}, revoked {{.RevokedAt.Format "2006-01-02 15:04"}}{{end}}</li>
+    {{- end}}
+    </ul>
+  </body>
+</html>
+`))
+
+// exportTimeout is how long an export may stay pending. A build that is lost
+// with the server running it would otherwise leave the export pending for
+// good; the purge job marks it failed after this.
+const exportTimeout = time.Hour
+
+// buildExport runs in the background after an export was requested and marks
+// the export as ready or failed once it's done. If the purge job has given up
+// on it in the meantime, the file is thrown away.
+func (cfg *apiConfig) buildExport(export database.DataExport) {
+	ctx := context.Background()
+
+	path, err := cfg.writeExport(ctx, export)
+	if err != nil {
+		log.Printf("Couldn't build export %s: %s", export.ID, err)
+		err = cfg.dbQueries.MarkDataExportFailed(ctx, export.ID)
+		if err != nil {
+			log.Printf("Couldn't mark export %s as failed: %s", export.ID, err)
+		}
+		return
+	}
+
+	marked, err := cfg.dbQueries.MarkDataExportReady(ctx, database.MarkDataExportReadyParams{
+		FilePath:  sql.NullString{String: path, Valid: true},
+		ExpiresAt: sql.NullTime{Time: time.Now().Add(cfg.exportTTL), Valid: true},
+		ID:        export.ID,
+	})
+	if err != nil {
+		log.Printf("Couldn't mark export %s as ready: %s", export.ID, err)
+		os.Remove(path)
+	} else if marked == 0 {
+		// The account was purged, or the export given up on as stale, while
+		// it was being built.
+		os.Remove(path)
+	}
+}
+
+func (cfg *apiConfig) writeExport(ctx context.Context, export database.DataExport) (string, error) {
+	data, err := cfg.collectExport(ctx, export.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	err = os.MkdirAll(cfg.exportDir, 0o700)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(cfg.exportDir, export.ID.String()+".zip")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", err
+	}
+
+	err = writeExportArchive(file, data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// A failed export has no expiry, so the purge job would never
+		// remove a partial file.
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+func writeExportArchive(w io.Writer, data accountExport) error {
+	archive := zip.NewWriter(w)
+
+	jsonFile, err := archive.Create("account.json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(jsonFile)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(data)
+	if err != nil {
+		return err
+	}
+
+	htmlFile, err := archive.Create("account.html")
+	if err != nil {
+		return err
+	}
+	err = exportHTML.Execute(htmlFile, data)
+	if err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+func (cfg *apiConfig) collectExport(ctx context.Context, userID uuid.UUID) (accountExport, error) {
+	user, err := cfg.dbQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return accountExport{}, fmt.Errorf("couldn't get user: %w", err)
+	}
+
+	chirps, err := cfg.dbQueries.GetChirpsByUserID(ctx, database.GetChirpsByUserIDParams{
+		UserID:        userID,
+		IncludeHidden: true,
+		ViewerID:      uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if err != nil {
+		return accountExport{}, fmt.Errorf("couldn't get chirps: %w", err)
+	}
+
+	drafts, err := cfg.dbQueries.GetDraftChirps(ctx, userID)
+	if err != nil {
+		return accountExport{}, fmt.Errorf("couldn't get drafts: %w", err)
+	}
+	chirps = append(chirps, drafts...)
+
+	tokens, err := cfg.dbQueries.GetTokensByUserID(ctx, userID)
+	if err != nil {
+		return accountExport{}, fmt.Errorf("couldn't get sessions: %w", err)
+	}
+
+	data := accountExport{
+		GeneratedAt: time.Now().UTC(),
+		Profile: exportProfile{
+			ID:        user.ID,
+			CreatedAt: user.CreatedAt,
+			UpdatedAt: user.UpdatedAt,
+			Email:     user.Email,
+		},
+		Subscription: exportSubscription{
+			Plan:        "free",
+			IsChirpyRed: user.IsChirpyRed,
+		},
+		Chirps:   []Chirp{},
+		Sessions: []exportSession{},
+	}
+	if user.IsChirpyRed {
+		data.Subscription.Plan = "chirpy_red"
+	}
+
+	for _, chirp := range chirps {
+		data.Chirps = append(data.Chirps, jsonChirp(chirp))
+	}
+
+	for _, token := range tokens {
+		session := exportSession{
+			CreatedAt: token.CreatedAt,
+			ExpiresAt: token.ExpiresAt,
+		}
+		if token.RevokedAt.Valid {
+			session.RevokedAt = &token.RevokedAt.Time
+		}
+		data.Sessions = append(data.Sessions, session)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/auth"
+	"github.com/hugermuger/chirpy/internal/database"
+)
+
+type Analytics struct {
+	Interval   string           `json:"interval"`
+	Since      time.Time        `json:"since"`
+	TotalViews int64            `json:"total_views"`
+	Chirps     []ChirpAnalytics `json:"chirps"`
+}
+
+type ChirpAnalytics struct {
+	ChirpID uuid.UUID    `json:"chirp_id"`
+	Views   int64        `json:"views"`
+	Buckets []ViewBucket `json:"buckets"`
+}
+
+type ViewBucket struct {
+	Start time.Time `json:"start"`
+	Views int64     `json:"views"`
+}
+
+// analyticsIntervals are the bucket sizes an author can ask for, with how far
+// back a report goes by default and at most.
+var analyticsIntervals = map[string]struct {
+	defaultRange time.Duration
+	maxRange     time.Duration
+}{
+	"hour": {defaultRange: 48 * time.Hour, maxRange: 7 * 24 * time.Hour},
+	"day":  {defaultRange: 30 * 24 * time.Hour, maxRange: 365 * 24 * time.Hour},
+}
+
+// getAnalytics reports the views of the caller's chirps per interval. Only
+// chirps that were seen in the period are listed. Recent views show up once
+// they have been flushed.
+func (cfg *apiConfig) getAnalytics(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get Bearer Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	interval := r.URL.Query().Get("interval")
+	if interval == "" {
+		interval = "day"
+	}
+	ranges, ok := analyticsIntervals[interval]
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "interval must be hour or day", nil)
+		return
+	}
+
+	now := time.Now()
+	since := now.Add(-ranges.defaultRange)
+	if s := r.URL.Query().Get("since"); s != "" {
+		since, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "since must be an RFC 3339 timestamp", err)
+			return
+		}
+	}
+	if now.Sub(since) > ranges.maxRange {
+		respondWithError(w, http.StatusBadRequest, "since is too far back for this interval", nil)
+		return
+	}
+
+	rows, err := cfg.dbQueries.GetChirpViewsByAuthor(r.Context(), database.GetChirpViewsByAuthorParams{
+		Unit:   interval,
+		UserID: userID,
+		Since:  since,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get analytics", err)
+		return
+	}
+
+	analytics := Analytics{
+		Interval: interval,
+		Since:    since,
+		Chirps:   []ChirpAnalytics{},
+	}
+	// Rows come ordered by chirp, so each chirp's buckets are together.
+	for _, row := range rows {
+		last := len(analytics.Chirps) - 1
+		if last < 0 || analytics.Chirps[last].ChirpID != row.ChirpID {
+			analytics.Chirps = append(analytics.Chirps, ChirpAnalytics{ChirpID: row.ChirpID})
+			last++
+		}
+		chirp := &analytics.Chirps[last]
+		chirp.Views += row.Views
+		chirp.Buckets = append(chirp.Buckets, ViewBucket{Start: row.Bucket, Views: row.Views})
+		analytics.TotalViews += row.Views
+	}
+
+	respondWithJSON(w, http.StatusOK, analytics)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/auth"
+	"github.com/hugermuger/chirpy/internal/database"
+	"github.com/hugermuger/chirpy/internal/media"
+)
+
+// Avatar URLs contain a hash of the picture, so a new avatar gets new URLs
+// and each URL can be cached for a year. Old URLs stop working once the
+// avatar is replaced.
+
+func avatarKey(userID uuid.UUID, hash string, size int) string {
+	return "avatar_" + userID.String() + "_" + hash + "_" + strconv.Itoa(size)
+}
+
+func avatarURLs(user database.User) map[string]string {
+	if !user.AvatarHash.Valid {
+		return nil
+	}
+	urls := map[string]string{}
+	for _, size := range media.AvatarSizes {
+		urls[strconv.Itoa(size)] = "/api/users/" + user.ID.String() + "/avatar/" + user.AvatarHash.String + "/" + strconv.Itoa(size)
+	}
+	return urls
+}
+
+func (cfg *apiConfig) putAvatar(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get Bearer Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	data, ok := readUpload(w, r)
+	if !ok {
+		return
+	}
+
+	avatar, err := media.ProcessAvatar(data)
+	if errors.Is(err, media.ErrUnsupportedType) {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Avatars must be PNG, JPEG or WebP images", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't process image", err)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find user", err)
+		return
+	}
+
+	for _, size := range media.AvatarSizes {
+		err = cfg.blobs.Put(r.Context(), avatarKey(userID, avatar.Hash, size), bytes.NewReader(avatar.Sizes[size]))
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't store avatar", err)
+			return
+		}
+	}
+
+	err = cfg.dbQueries.SetUserAvatar(r.Context(), database.SetUserAvatarParams{
+		AvatarHash: sql.NullString{String: avatar.Hash, Valid: true},
+		ID:         userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update avatar", err)
+		return
+	}
+
+	if user.AvatarHash.Valid && user.AvatarHash.String != avatar.Hash {
+		cfg.deleteAvatarBlobs(r.Context(), userID, user.AvatarHash.String)
+	}
+
+	user.AvatarHash = sql.NullString{String: avatar.Hash, Valid: true}
+	respondWithJSON(w, http.StatusOK, jsonUser(user))
+}
+
+func (cfg *apiConfig) deleteAvatar(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get Bearer Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find user", err)
+		return
+	}
+
+	err = cfg.dbQueries.SetUserAvatar(r.Context(), database.SetUserAvatarParams{ID: userID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't remove avatar", err)
+		return
+	}
+
+	if user.AvatarHash.Valid {
+		cfg.deleteAvatarBlobs(r.Context(), userID, user.AvatarHash.String)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) getAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	size, err := strconv.Atoi(r.PathValue("size"))
+	if err != nil || !slices.Contains(media.AvatarSizes, size) {
+		respondWithError(w, http.StatusNotFound, "Unknown avatar size", err)
+		return
+	}
+
+	hash := r.PathValue("hash")
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil || user.DeletedAt.Valid || user.AvatarHash.String != hash {
+		respondWithError(w, http.StatusNotFound, "Couldn't find avatar", err)
+		return
+	}
+
+	blob, err := cfg.blobs.Open(r.Context(), avatarKey(userID, hash, size))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find avatar", err)
+		return
+	}
+	defer blob.Close()
+
+	head := make([]byte, 12)
+	n, _ := io.ReadFull(blob, head)
+	contentType, err := media.Detect(head[:n])
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't read avatar", err)
+		return
+	}
+	_, err = blob.Seek(0, io.SeekStart)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't read avatar", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", `"`+hash+"-"+strconv.Itoa(size)+`"`)
+	http.ServeContent(w, r, "", user.UpdatedAt, blob)
+}
+
+func (cfg *apiConfig) deleteAvatarBlobs(ctx context.Context, userID uuid.UUID, hash string) {
+	for _, size := range media.AvatarSizes {
+		cfg.blobs.Delete(ctx, avatarKey(userID, hash, size))
+	}
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/auth"
+	"github.com/hugermuger/chirpy/internal/database"
+	"github.com/lib/pq"
+)
+
+// Bookmarks are private to the user who made them. Each bookmark can sit in
+// one of the user's named collections; deleting a collection keeps its
+// bookmarks, unsorted.
+
+const maxCollectionNameLength = 64
+
+type Bookmark struct {
+	CreatedAt    time.Time  `json:"created_at"`
+	CollectionID *uuid.UUID `json:"collection_id"`
+	Chirp        Chirp      `json:"chirp"`
+}
+
+type BookmarkCollection struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `json:"name"`
+}
+
+type setCollection struct {
+	Name string `json:"name"`
+}
+
+func (cfg *apiConfig) bookmarkChirp(w http.ResponseWriter, r *http.Request) {
+	type setBookmark struct {
+		CollectionID *uuid.UUID `json:"collection_id"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get Bearer Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	// The body is optional; without one the bookmark is unsorted.
+	decoder := json.NewDecoder(r.Body)
+	bookmarkIn := setBookmark{}
+	err = decoder.Decode(&bookmarkIn)
+	if err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	chirp, err := cfg.dbQueries.GetChirp(r.Context(), database.GetChirpParams{
+		ID:       chirpID,
+		ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp", err)
+		return
+	}
+
+	collectionID := uuid.NullUUID{}
+	if bookmarkIn.CollectionID != nil {
+		collection, err := cfg.dbQueries.GetBookmarkCollection(r.Context(), database.GetBookmarkCollectionParams{
+			ID:     *bookmarkIn.CollectionID,
+			UserID: userID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "Couldn't find collection", err)
+			return
+		}
+		collectionID = uuid.NullUUID{UUID: collection.ID, Valid: true}
+	}
+
+	err = cfg.dbQueries.UpsertBookmark(r.Context(), database.UpsertBookmarkParams{
+		UserID:       userID,
+		ChirpID:      chirp.ID,
+		CollectionID: collectionID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save bookmark", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) unbookmarkChirp(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get Bearer Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	err = cfg.dbQueries.DeleteBookmark(r.Context(), database.DeleteBookmarkParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't remove bookmark", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listBookmarks returns the caller's bookmarks, newest first, one page at a
+// time. Bookmarked chirps the caller can no longer see are left out.
+func (cfg *apiConfig) listBookmarks(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get Bearer Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	page, err := parsePage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	collectionID := uuid.NullUUID{}
+	if s := r.URL.Query().Get("collection_id"); s != "" {
+		collectionID.UUID, err = uuid.Parse(s)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid collection ID", err)
+			return
+		}
+		collectionID.Valid = true
+	}
+
+	rows, err := cfg.dbQueries.GetBookmarks(r.Context(), database.GetBookmarksParams{
+		UserID:       userID,
+		CollectionID: collectionID,
+		Before:       page.Before,
+		BeforeID:     page.BeforeID,
+		PageSize:     page.Size,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get bookmarks", err)
+		return
+	}
+
+	jsonChirps := []Chirp{}
+	for _, row := range rows {
+		jsonChirps = append(jsonChirps, jsonChirp(row.Chirp))
+	}
+	err = cfg.expandChirps(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, jsonChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp details", err)
+		return
+	}
+
+	bookmarks := []Bookmark{}
+	for i, row := range rows {
+		bookmark := Bookmark{
+			CreatedAt: row.BookmarkedAt,
+			Chirp:     jsonChirps[i],
+		}
+		if row.CollectionID.Valid {
+			bookmark.CollectionID = &row.CollectionID.UUID
+		}
+		bookmarks = append(bookmarks, bookmark)
+	}
+
+	if len(rows) == int(page.Size) {
+		last := rows[len(rows)-1]
+		setNextPage(w, r, last.BookmarkedAt, last.Chirp.ID)
+	}
+	respondWithJSON(w, http.StatusOK, bookmarks)
+}
+
+func (cfg *apiConfig) listBookmarkCollections(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get Bearer Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	collections, err := cfg.dbQueries.GetBookmarkCollections(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get collections", err)
+		return
+	}
+
+	jsonCollections := []BookmarkCollection{}
+	for _, collection := range collections {
+		jsonCollections = append(jsonCollections, jsonBookmarkCollection(collection))
+	}
+
+	respondWithJSON(w, http.StatusOK, jsonCollections)
+}
+
+func (cfg *apiConfig) createBookmarkCollection(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get Bearer Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	collectionIn, ok := decodeCollection(w, r)
+	if !ok {
+		return
+	}
+
+	collection, err := cfg.dbQueries.CreateBookmarkCollection(r.Context(), database.CreateBookmarkCollectionParams{
+		UserID: userID,
+		Name:   collectionIn.Name,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "A collection with that name already exists", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create collection", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, jsonBookmarkCollection(collection))
+}
+
+func (cfg *apiConfig) renameBookmarkCollection(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get Bearer Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	collectionID, err := uuid.Parse(r.PathValue("collectionID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid collection ID", err)
+		return
+	}
+
+	collectionIn, ok := decodeCollection(w, r)
+	if !ok {
+		return
+	}
+
+	collection, err := cfg.dbQueries.RenameBookmarkCollection(r.Context(), database.RenameBookmarkCollectionParams{
+		Name:   collectionIn.Name,
+		ID:     collectionID,
+		UserID: userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find collection", err)
+		return
+	}
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "A collection with that name already exists", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't rename collection", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, jsonBookmarkCollection(collection))
+}
+
+func (cfg *apiConfig) deleteBookmarkCollection(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get Bearer Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	collectionID, err := uuid.Parse(r.PathValue("collectionID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid collection ID", err)
+		return
+	}
+
+	deleted, err := cfg.dbQueries.DeleteBookmarkCollection(r.Context(), database.DeleteBookmarkCollectionParams{
+		ID:     collectionID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete collection", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find collection", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeCollection reads and checks a collection from the request body. It
+// responds with an error and returns false if that fails.
+func decodeCollection(w http.ResponseWriter, r *http.Request) (setCollection, bool) {
+	decoder := json.NewDecoder(r.Body)
+	collectionIn := setCollection{}
+	err := decoder.Decode(&collectionIn)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return setCollection{}, false
+	}
+
+	collectionIn.Name = strings.TrimSpace(collectionIn.Name)
+	if collectionIn.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "Collection name is required", nil)
+		return setCollection{}, false
+	}
+	if utf8.RuneCountInString(collectionIn.Name) > maxCollectionNameLength {
+		respondWithError(w, http.StatusBadRequest, "Collection name is too long", nil)
+		return setCollection{}, false
+	}
+	return collectionIn, true
+}
+
+func jsonBookmarkCollection(collection database.BookmarkCollection) BookmarkCollection {
+	return BookmarkCollection{
+		ID:        collection.ID,
+		CreatedAt: collection.CreatedAt,
+		UpdatedAt: collection.UpdatedAt,
+		Name:      collection.Name,
+	}
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -1,37 +1,61 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"slices"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/hugermuger/chirpy/internal/auth"
+	"github.com/hugermuger/chirpy/internal/chirptext"
 	"github.com/hugermuger/chirpy/internal/database"
+	"github.com/hugermuger/chirpy/internal/moderation"
+	"github.com/hugermuger/chirpy/internal/spam"
 )
 
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Body        string     `json:"body"`
+	UserID      uuid.UUID  `json:"user_id"`
+	Visibility  string     `json:"visibility"`
+	Status      string     `json:"status"`
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
+	Poll        *Poll      `json:"poll,omitempty"`
+	Media       []Media    `json:"media,omitempty"`
+	Links       []Link     `json:"links,omitempty"`
 }
 
-func (cfg *apiConfig) addChirp(w http.ResponseWriter, r *http.Request) {
-	type setChirp struct {
-		Body string `json:"body"`
-	}
+// setChirp is the body of a new chirp or an edited draft. A chirp with
+// scheduled_at is published by the scheduler at that time; one with draft set
+// stays unpublished until it's edited to have a time.
+type setChirp struct {
+	Body        string     `json:"body"`
+	Visibility  string     `json:"visibility"`
+	Draft       bool       `json:"draft"`
+	ScheduledAt *time.Time `json:"scheduled_at"`
+	Poll        *setPoll   `json:"poll"`
+	Media       []setMedia `json:"media"`
+}
+
+// chirpVisibilities are who a chirp can be shown to. Unlisted chirps are left
+// out of the global listing but can be opened by any logged-in user; anyone
+// not logged in only ever sees public chirps.
+var chirpVisibilities = []string{"public", "followers", "unlisted", "private"}
 
+func (cfg *apiConfig) addChirp(w http.ResponseWriter, r *http.Request) {
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't get Bearer Token", err)
 		return
 	}
 
-	testID, err := auth.ValidateJWT(token, cfg.secret)
+	testID, err := cfg.validateAccessToken(r.Context(), token)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
 		return
@@ -45,43 +69,188 @@ func (cfg *apiConfig) addChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(chirpIn.Body) > 140 {
-		respondWithError(w, http.StatusBadRequest, "Chirp is too long", nil)
+	status, scheduledAt, err := chirpIn.state()
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	if status != "published" && (chirpIn.Poll != nil || len(chirpIn.Media) > 0) {
+		respondWithError(w, http.StatusBadRequest, "Polls and media can't be added to drafts or scheduled chirps", nil)
 		return
 	}
 
-	chirpIn.Body = profaneFilter(chirpIn.Body)
+	err = validateMedia(chirpIn.Media)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	pollDuration := time.Duration(0)
+	if chirpIn.Poll != nil {
+		pollDuration, err = chirpIn.Poll.validate(cfg.moderator)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+	}
+
+	author, err := cfg.dbQueries.GetUserByID(r.Context(), testID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find user", err)
+		return
+	}
+
+	moderated, err := cfg.validateChirp(chirpIn.Body, author)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	if status != "published" {
+		chirp, err := cfg.dbQueries.CreateChirp(r.Context(), database.CreateChirpParams{
+			Body:        moderated.Text,
+			UserID:      testID,
+			Visibility:  chirpIn.Visibility,
+			Status:      status,
+			ScheduledAt: scheduledAt,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't save draft", err)
+			return
+		}
+		respondWithJSON(w, http.StatusCreated, jsonChirp(chirp))
+		return
+	}
+
+	decision, err := cfg.scoreChirp(r.Context(), author, moderated.Text)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't score chirp", err)
+		return
+	}
+	if decision.Verdict == spam.Limit {
+		w.Header().Set("Retry-After", cfg.retryAfterSeconds(decision))
+		respondWithError(w, http.StatusTooManyRequests, "You're posting too fast, try again later", nil)
+		return
+	}
 
 	params := database.CreateChirpParams{
-		Body:   chirpIn.Body,
-		UserID: testID,
+		Body:       moderated.Text,
+		UserID:     testID,
+		Visibility: chirpIn.Visibility,
+		Status:     status,
+	}
+	if decision.Verdict == spam.Queue {
+		params.Status = "pending"
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	duplicate, err := cfg.isDuplicateChirp(r.Context(), q, testID, moderated.Text)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check for duplicate chirps", err)
+		return
+	}
+	if duplicate {
+		respondWithError(w, http.StatusConflict, "You already posted this chirp recently", nil)
+		return
+	}
+
+	chirp, err := q.CreateChirp(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't set chirp in database", err)
+		return
 	}
 
-	chirp, err := cfg.dbQueries.CreateChirp(r.Context(), params)
+	if chirpIn.Poll != nil {
+		err = createPoll(r.Context(), q, chirp.ID, *chirpIn.Poll, pollDuration)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create poll", err)
+			return
+		}
+	}
+
+	for i, m := range chirpIn.Media {
+		attached, err := q.AttachToChirp(r.Context(), database.AttachToChirpParams{
+			ChirpID:  uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			AltText:  m.AltText,
+			Position: int32(i),
+			ID:       m.ID,
+			UserID:   testID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't attach media", err)
+			return
+		}
+		if attached == 0 {
+			respondWithError(w, http.StatusBadRequest, "Unknown or already used media "+m.ID.String(), nil)
+			return
+		}
+	}
+
+	if decision.Verdict == spam.Queue {
+		err = holdForReview(r.Context(), q, chirp.ID, decision)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't queue chirp for review", err)
+			return
+		}
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't set chirp in database", err)
 		return
 	}
 
-	jsonChirp := jsonChirp(chirp)
+	cfg.recordFlags(r.Context(), chirp.ID, moderated)
+	code := http.StatusCreated
+	if chirp.Status == "pending" {
+		// Held chirps are announced when a moderator approves them.
+		code = http.StatusAccepted
+	} else {
+		cfg.queueLinkPreviews(chirp.Body)
+		cfg.publishChirp(r.Context(), chirp, author.ShadowBanned)
+	}
 
-	respondWithJSON(w, http.StatusCreated, jsonChirp)
+	jsonChirps := []Chirp{jsonChirp(chirp)}
+	err = cfg.expandChirps(r.Context(), uuid.NullUUID{UUID: testID, Valid: true}, jsonChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp details", err)
+		return
+	}
+
+	respondWithJSON(w, code, jsonChirps[0])
 }
 
 func (cfg *apiConfig) getChirps(w http.ResponseWriter, r *http.Request) {
 	s := r.URL.Query().Get("author_id")
 	sort := r.URL.Query().Get("sort")
 	chirps := []database.Chirp{}
+	viewer, loggedIn := cfg.viewer(r)
+	viewerID := uuid.NullUUID{UUID: viewer.ID, Valid: loggedIn}
 
 	if s != "" {
-		chirp, err := cfg.dbQueries.GetChirpsByUserID(r.Context(), uuid.MustParse(s))
+		chirp, err := cfg.dbQueries.GetChirpsByUserID(r.Context(), database.GetChirpsByUserIDParams{
+			UserID:        uuid.MustParse(s),
+			IncludeHidden: viewer.IsModerator,
+			ViewerID:      viewerID,
+		})
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps", err)
 			return
 		}
 		chirps = chirp
 	} else {
-		chirp, err := cfg.dbQueries.GetChirps(r.Context())
+		chirp, err := cfg.dbQueries.GetChirps(r.Context(), database.GetChirpsParams{
+			IncludeHidden: viewer.IsModerator,
+			ViewerID:      viewerID,
+		})
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps", err)
 			return
@@ -94,27 +263,48 @@ func (cfg *apiConfig) getChirps(w http.ResponseWriter, r *http.Request) {
 	if sort == "desc" {
 		slices.SortFunc(chirps, func(a, b database.Chirp) int { return b.CreatedAt.Compare(a.CreatedAt) })
 	}
+	cfg.recordViews(r, viewerID, chirps)
 
 	for _, chirp := range chirps {
 		jsonChirps = append(jsonChirps, jsonChirp(chirp))
 	}
 
+	err := cfg.expandChirps(r.Context(), viewerID, jsonChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp details", err)
+		return
+	}
+
 	respondWithJSON(w, http.StatusOK, jsonChirps)
 }
 
 func (cfg *apiConfig) getChirp(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("chirpID")
-	chirp, err := cfg.dbQueries.GetChirp(r.Context(), uuid.MustParse(id))
+	viewer, loggedIn := cfg.viewer(r)
+	viewerID := uuid.NullUUID{UUID: viewer.ID, Valid: loggedIn}
+	chirp, err := cfg.dbQueries.GetChirp(r.Context(), database.GetChirpParams{
+		ID:            uuid.MustParse(id),
+		IncludeHidden: viewer.IsModerator,
+		ViewerID:      viewerID,
+	})
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "Couldn't get chirp", err)
 		return
 	}
+	cfg.recordViews(r, viewerID, []database.Chirp{chirp})
 
-	jsonChirp := jsonChirp(chirp)
+	jsonChirps := []Chirp{jsonChirp(chirp)}
+	err = cfg.expandChirps(r.Context(), viewerID, jsonChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp details", err)
+		return
+	}
 
-	respondWithJSON(w, http.StatusOK, jsonChirp)
+	respondWithJSON(w, http.StatusOK, jsonChirps[0])
 }
 
+// deleteChirp moves a published chirp to the trash, where its author can
+// restore it until the purge job removes it for good.
 func (cfg *apiConfig) deleteChirp(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("chirpID")
 
@@ -124,24 +314,34 @@ func (cfg *apiConfig) deleteChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	testID, err := auth.ValidateJWT(token, cfg.secret)
+	testID, err := cfg.validateAccessToken(r.Context(), token)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
 		return
 	}
 
-	chirp, err := cfg.dbQueries.GetChirp(r.Context(), uuid.MustParse(id))
+	chirp, err := cfg.dbQueries.GetChirp(r.Context(), database.GetChirpParams{
+		ID:            uuid.MustParse(id),
+		IncludeHidden: true,
+		ViewerID:      uuid.NullUUID{UUID: testID, Valid: true},
+	})
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "Couldn't find chirp", err)
 		return
 	}
 
 	if chirp.UserID == testID {
-		err = cfg.dbQueries.DeleteChirp(r.Context(), uuid.MustParse(id))
+		author, err := cfg.dbQueries.GetUserByID(r.Context(), testID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't find user", err)
+			return
+		}
+		err = cfg.dbQueries.SoftDeleteChirp(r.Context(), uuid.MustParse(id))
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp", err)
 			return
 		}
+		cfg.publishDeletion(chirp, author.ShadowBanned)
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
@@ -150,26 +350,96 @@ func (cfg *apiConfig) deleteChirp(w http.ResponseWriter, r *http.Request) {
 }
 
 func jsonChirp(chirp database.Chirp) Chirp {
-	return Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
+	result := Chirp{
+		ID:         chirp.ID,
+		CreatedAt:  chirp.CreatedAt,
+		UpdatedAt:  chirp.UpdatedAt,
+		Body:       chirp.Body,
+		UserID:     chirp.UserID,
+		Visibility: chirp.Visibility,
+		Status:     chirp.Status,
+	}
+	if chirp.ScheduledAt.Valid {
+		result.ScheduledAt = &chirp.ScheduledAt.Time
 	}
+	return result
 }
 
-func profaneFilter(s string) string {
-	words := strings.Split(s, " ")
-	expWords := []string{}
-	for _, word := range words {
-		if strings.ToLower(word) == "kerfuffle" ||
-			strings.ToLower(word) == "sharbert" ||
-			strings.ToLower(word) == "fornax" {
-			word = "****"
+// chirpLimits are the maximum chirp lengths per account tier, as counted by
+// chirptext.Length.
+type chirpLimits struct {
+	Default   int
+	ChirpyRed int
+}
+
+func (l chirpLimits) forUser(user database.User) int {
+	if user.IsChirpyRed {
+		return l.ChirpyRed
+	}
+	return l.Default
+}
+
+var (
+	errChirpTooLong       = errors.New("Chirp is too long")
+	errChirpRejected      = errors.New("Chirp violates the content rules")
+	errUnknownVisibility  = errors.New("Unknown visibility")
+	errScheduledInThePast = errors.New("Scheduled time must be in the future")
+)
+
+// state checks the visibility and works out the status and publish time the
+// chirp is stored with. It fills in the default visibility.
+func (in *setChirp) state() (string, sql.NullTime, error) {
+	if in.Visibility == "" {
+		in.Visibility = "public"
+	} else if !slices.Contains(chirpVisibilities, in.Visibility) {
+		return "", sql.NullTime{}, errUnknownVisibility
+	}
+
+	switch {
+	case in.ScheduledAt != nil:
+		if !in.ScheduledAt.After(time.Now()) {
+			return "", sql.NullTime{}, errScheduledInThePast
 		}
-		expWords = append(expWords, word)
+		return "scheduled", sql.NullTime{Time: *in.ScheduledAt, Valid: true}, nil
+	case in.Draft:
+		return "draft", sql.NullTime{}, nil
+	default:
+		return "published", sql.NullTime{}, nil
 	}
+}
 
-	return strings.Join(expWords, " ")
+// validateChirp checks a chirp body against the author's length and size
+// limits and runs it through the moderation pipeline. The returned result
+// holds the text to store.
+func (cfg *apiConfig) validateChirp(body string, author database.User) (moderation.Result, error) {
+	limit := cfg.chirpLimits.forUser(author)
+	if !chirptext.Fits(body, limit) {
+		return moderation.Result{}, errChirpTooLong
+	}
+
+	result := cfg.moderator.Moderate(body)
+	if result.Action == moderation.Reject {
+		return result, errChirpRejected
+	}
+	return result, nil
+}
+
+// isDuplicateChirp reports whether the user published body within the
+// duplicate window. q must be bound to the transaction that inserts the
+// chirp: it holds the user's chirp lock from here until the transaction
+// ends, so concurrent identical posts can't both get through.
+func (cfg *apiConfig) isDuplicateChirp(ctx context.Context, q *database.Queries, userID uuid.UUID, body string) (bool, error) {
+	if cfg.duplicateWindow <= 0 {
+		return false, nil
+	}
+
+	err := q.LockUserChirps(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return q.HasRecentDuplicateChirp(ctx, database.HasRecentDuplicateChirpParams{
+		UserID:    userID,
+		Body:      body,
+		CreatedAt: time.Now().Add(-cfg.duplicateWindow),
+	})
 }
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/auth"
+	"github.com/hugermuger/chirpy/internal/database"
+)
+
+// Drafts and scheduled chirps are created through POST /api/chirps and only
+// show up here until they are published.
+
+func (cfg *apiConfig) listDrafts(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get Bearer Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	drafts, err := cfg.dbQueries.GetDraftChirps(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get drafts", err)
+		return
+	}
+
+	jsonDrafts := []Chirp{}
+	for _, draft := range drafts {
+		jsonDrafts = append(jsonDrafts, jsonChirp(draft))
+	}
+
+	respondWithJSON(w, http.StatusOK, jsonDrafts)
+}
+
+// updateDraft replaces a draft or scheduled chirp. Without scheduled_at the
+// chirp becomes (or stays) a draft.
+func (cfg *apiConfig) updateDraft(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get Bearer Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	draftIn := setChirp{}
+	err = decoder.Decode(&draftIn)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	draftIn.Draft = true
+	status, scheduledAt, err := draftIn.state()
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	author, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find user", err)
+		return
+	}
+
+	moderated, err := cfg.validateChirp(draftIn.Body, author)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	draft, err := cfg.dbQueries.UpdateDraftChirp(r.Context(), database.UpdateDraftChirpParams{
+		Body:        moderated.Text,
+		Visibility:  draftIn.Visibility,
+		Status:      status,
+		ScheduledAt: scheduledAt,
+		ID:          chirpID,
+		UserID:      userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find draft", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update draft", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, jsonChirp(draft))
+}
+
+func (cfg *apiConfig) cancelDraft(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get Bearer Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	deleted, err := cfg.dbQueries.DeleteDraftChirp(r.Context(), database.DeleteDraftChirpParams{
+		ID:     chirpID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete draft", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find draft", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/auth"
+	"github.com/hugermuger/chirpy/internal/database"
+)
+
+type DataExport struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Status      string     `json:"status"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"`
+}
+
+func (cfg *apiConfig) requestExport(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	export, err := cfg.dbQueries.CreateDataExport(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create export", err)
+		return
+	}
+
+	go cfg.buildExport(export)
+
+	respondWithJSON(w, http.StatusAccepted, cfg.jsonDataExport(export))
+}
+
+func (cfg *apiConfig) getExport(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	exportID, err := uuid.Parse(r.PathValue("exportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid export ID", err)
+		return
+	}
+
+	export, err := cfg.dbQueries.GetDataExport(r.Context(), exportID)
+	if err != nil || export.UserID != userID {
+		respondWithError(w, http.StatusNotFound, "Couldn't find export", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, cfg.jsonDataExport(export))
+}
+
+// downloadExport is authorized by the signed link handed out by getExport
+// instead of a bearer token, so it can be opened directly in a browser.
+func (cfg *apiConfig) downloadExport(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("exportID")
+	query := r.URL.Query()
+
+	err := auth.ValidateSignedLink(id, query.Get("expires"), query.Get("signature"), cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusForbidden, "Invalid or expired link", err)
+		return
+	}
+
+	exportID, err := uuid.Parse(id)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid export ID", err)
+		return
+	}
+
+	export, err := cfg.dbQueries.GetDataExport(r.Context(), exportID)
+	if err != nil || export.Status != "ready" || !export.FilePath.Valid {
+		respondWithError(w, http.StatusNotFound, "Couldn't find export", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="chirpy-export-%s.zip"`, export.ID))
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.ServeFile(w, r, export.FilePath.String)
+}
+
+func (cfg *apiConfig) jsonDataExport(export database.DataExport) DataExport {
+	result := DataExport{
+		ID:        export.ID,
+		CreatedAt: export.CreatedAt,
+		Status:    export.Status,
+	}
+
+	if export.Status == "ready" && export.ExpiresAt.Valid {
+		expiresAt := export.ExpiresAt.Time
+		query := url.Values{}
+		query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+		query.Set("signature", auth.SignLink(export.ID.String(), expiresAt, cfg.secret))
+
+		result.ExpiresAt = &expiresAt
+		result.DownloadURL = "/api/exports/" + export.ID.String() + "/download?" + query.Encode()
+	}
+
+	return result
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/hugermuger/chirpy/internal/auth"
+	"github.com/hugermuger/chirpy/internal/chirpimport"
+)
+
+// importChirpsHandler accepts a JSON Lines or CSV archive as the request body.
+// The format comes from the format query parameter or the Content-Type.
+func (cfg *apiConfig) importChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get Bearer Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	format := chirpimport.Format(r.URL.Query().Get("format"), r.Header.Get("Content-Type"))
+	if format != chirpimport.FormatJSONL && format != chirpimport.FormatCSV {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Archive must be JSON Lines or CSV", nil)
+		return
+	}
+
+	// The whole archive is read before anything is stored, so one that turns
+	// out to be too large doesn't leave half of it imported.
+	archive, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Archive is too large", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't read archive", err)
+		return
+	}
+
+	result, err := cfg.importChirps(r.Context(), userID, bytes.NewReader(archive), format)
+	if err != nil {
+		// Rows before the unreadable part are already stored, so the
+		// result still says which.
+		result.Error = "Couldn't read archive: " + err.Error()
+		respondWithJSON(w, http.StatusBadRequest, result)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
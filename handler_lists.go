@@ -0,0 +1,405 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/auth"
+	"github.com/hugermuger/chirpy/internal/database"
+)
+
+// Lists group accounts picked by their owner. A public list, its members and
+// its timeline can be read by anyone; a private one only by its owner.
+// Members aren't told they were added.
+
+const (
+	maxListNameLength        = 64
+	maxListDescriptionLength = 280
+	maxListMembers           = 1000
+)
+
+type List struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	OwnerID     uuid.UUID `json:"owner_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Private     bool      `json:"private"`
+}
+
+type ListMember struct {
+	UserID  uuid.UUID `json:"user_id"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+type setList struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Private     bool   `json:"private"`
+}
+
+func (cfg *apiConfig) createList(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get Bearer Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	listIn, ok := decodeList(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := cfg.dbQueries.CreateList(r.Context(), database.CreateListParams{
+		OwnerID:     userID,
+		Name:        listIn.Name,
+		Description: listIn.Description,
+		Private:     listIn.Private,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create list", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, jsonList(list))
+}
+
+func (cfg *apiConfig) getList(w http.ResponseWriter, r *http.Request) {
+	list, ok := cfg.visibleList(w, r)
+	if !ok {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, jsonList(list))
+}
+
+// getUserLists returns the lists a user owns. Their private lists are only
+// included for the user themselves.
+func (cfg *apiConfig) getUserLists(w http.ResponseWriter, r *http.Request) {
+	ownerID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	viewer, loggedIn := cfg.viewer(r)
+	lists, err := cfg.dbQueries.GetListsByOwner(r.Context(), database.GetListsByOwnerParams{
+		OwnerID:        ownerID,
+		IncludePrivate: loggedIn && viewer.ID == ownerID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get lists", err)
+		return
+	}
+
+	jsonLists := []List{}
+	for _, list := range lists {
+		jsonLists = append(jsonLists, jsonList(list))
+	}
+
+	respondWithJSON(w, http.StatusOK, jsonLists)
+}
+
+func (cfg *apiConfig) updateList(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get Bearer Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	listID, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid list ID", err)
+		return
+	}
+
+	listIn, ok := decodeList(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := cfg.dbQueries.UpdateList(r.Context(), database.UpdateListParams{
+		Name:        listIn.Name,
+		Description: listIn.Description,
+		Private:     listIn.Private,
+		ID:          listID,
+		OwnerID:     userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find list", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, jsonList(list))
+}
+
+func (cfg *apiConfig) deleteList(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get Bearer Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	listID, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid list ID", err)
+		return
+	}
+
+	deleted, err := cfg.dbQueries.DeleteList(r.Context(), database.DeleteListParams{
+		ID:      listID,
+		OwnerID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete list", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find list", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) getListMembers(w http.ResponseWriter, r *http.Request) {
+	list, ok := cfg.visibleList(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := cfg.dbQueries.GetListMembers(r.Context(), list.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get list members", err)
+		return
+	}
+
+	jsonMembers := []ListMember{}
+	for _, member := range members {
+		jsonMembers = append(jsonMembers, ListMember{
+			UserID:  member.UserID,
+			AddedAt: member.CreatedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, jsonMembers)
+}
+
+func (cfg *apiConfig) addListMember(w http.ResponseWriter, r *http.Request) {
+	cfg.changeListMember(w, r, func(list database.List, memberID uuid.UUID) bool {
+		count, err := cfg.dbQueries.CountListMembers(r.Context(), list.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get list members", err)
+			return false
+		}
+		if count >= maxListMembers {
+			respondWithError(w, http.StatusConflict, "List is full", nil)
+			return false
+		}
+
+		member, err := cfg.dbQueries.GetUserByID(r.Context(), memberID)
+		if err != nil || member.DeletedAt.Valid {
+			respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+			return false
+		}
+
+		err = cfg.dbQueries.AddListMember(r.Context(), database.AddListMemberParams{
+			ListID: list.ID,
+			UserID: member.ID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't add list member", err)
+			return false
+		}
+		return true
+	})
+}
+
+func (cfg *apiConfig) removeListMember(w http.ResponseWriter, r *http.Request) {
+	cfg.changeListMember(w, r, func(list database.List, memberID uuid.UUID) bool {
+		err := cfg.dbQueries.RemoveListMember(r.Context(), database.RemoveListMemberParams{
+			ListID: list.ID,
+			UserID: memberID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't remove list member", err)
+			return false
+		}
+		return true
+	})
+}
+
+// changeListMember authenticates the list's owner and applies change to the
+// user in the path. change responds itself when it fails.
+func (cfg *apiConfig) changeListMember(w http.ResponseWriter, r *http.Request, change func(list database.List, memberID uuid.UUID) bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get Bearer Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	listID, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid list ID", err)
+		return
+	}
+
+	memberID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	list, err := cfg.dbQueries.GetList(r.Context(), listID)
+	if err != nil || list.OwnerID != userID {
+		respondWithError(w, http.StatusNotFound, "Couldn't find list", err)
+		return
+	}
+
+	if !change(list, memberID) {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getListChirps is the list's timeline: chirps by its members that the
+// viewer may see, newest first, paged like the bookmarks.
+func (cfg *apiConfig) getListChirps(w http.ResponseWriter, r *http.Request) {
+	list, ok := cfg.visibleList(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := parsePage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	viewer, loggedIn := cfg.viewer(r)
+	viewerID := uuid.NullUUID{UUID: viewer.ID, Valid: loggedIn}
+	chirps, err := cfg.dbQueries.GetListChirps(r.Context(), database.GetListChirpsParams{
+		ListID:   list.ID,
+		Before:   page.Before,
+		BeforeID: page.BeforeID,
+		ViewerID: viewerID,
+		PageSize: page.Size,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps", err)
+		return
+	}
+
+	jsonChirps := []Chirp{}
+	for _, chirp := range chirps {
+		jsonChirps = append(jsonChirps, jsonChirp(chirp))
+	}
+
+	err = cfg.expandChirps(r.Context(), viewerID, jsonChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp details", err)
+		return
+	}
+
+	if len(chirps) == int(page.Size) {
+		last := chirps[len(chirps)-1]
+		setNextPage(w, r, last.CreatedAt, last.ID)
+	}
+	respondWithJSON(w, http.StatusOK, jsonChirps)
+}
+
+// visibleList returns the list in the path if the viewer may read it. It
+// responds with an error and returns false otherwise; a private list looks
+// the same as a missing one.
+func (cfg *apiConfig) visibleList(w http.ResponseWriter, r *http.Request) (database.List, bool) {
+	listID, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid list ID", err)
+		return database.List{}, false
+	}
+
+	list, err := cfg.dbQueries.GetList(r.Context(), listID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find list", err)
+		return database.List{}, false
+	}
+
+	if list.Private {
+		viewer, loggedIn := cfg.viewer(r)
+		if !loggedIn || viewer.ID != list.OwnerID {
+			respondWithError(w, http.StatusNotFound, "Couldn't find list", nil)
+			return database.List{}, false
+		}
+	}
+	return list, true
+}
+
+// decodeList reads and checks a list from the request body. It responds with
+// an error and returns false if that fails.
+func decodeList(w http.ResponseWriter, r *http.Request) (setList, bool) {
+	decoder := json.NewDecoder(r.Body)
+	listIn := setList{}
+	err := decoder.Decode(&listIn)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return setList{}, false
+	}
+
+	listIn.Name = strings.TrimSpace(listIn.Name)
+	listIn.Description = strings.TrimSpace(listIn.Description)
+	if listIn.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "List name is required", nil)
+		return setList{}, false
+	}
+	if utf8.RuneCountInString(listIn.Name) > maxListNameLength {
+		respondWithError(w, http.StatusBadRequest, "List name is too long", nil)
+		return setList{}, false
+	}
+	if utf8.RuneCountInString(listIn.Description) > maxListDescriptionLength {
+		respondWithError(w, http.StatusBadRequest, "List description is too long", nil)
+		return setList{}, false
+	}
+	return listIn, true
+}
+
+func jsonList(list database.List) List {
+	return List{
+		ID:          list.ID,
+		CreatedAt:   list.CreatedAt,
+		UpdatedAt:   list.UpdatedAt,
+		OwnerID:     list.OwnerID,
+		Name:        list.Name,
+		Description: list.Description,
+		Private:     list.Private,
+	}
+}
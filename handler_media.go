@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/auth"
+	"github.com/hugermuger/chirpy/internal/chirptext"
+	"github.com/hugermuger/chirpy/internal/database"
+	"github.com/hugermuger/chirpy/internal/media"
+)
+
+// uploadMedia takes a multipart form with the image in the "file" field. The
+// upload can then be attached to a chirp by its ID.
+func (cfg *apiConfig) uploadMedia(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get Bearer Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	data, ok := readUpload(w, r)
+	if !ok {
+		return
+	}
+
+	processed, err := media.Process(data)
+	if errors.Is(err, media.ErrUnsupportedType) {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Only JPEG, PNG, GIF and WebP images are supported", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't process image", err)
+		return
+	}
+
+	id := uuid.New()
+	err = cfg.blobs.Put(r.Context(), blobKey(id), bytes.NewReader(processed.Data))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't store upload", err)
+		return
+	}
+	err = cfg.blobs.Put(r.Context(), thumbnailKey(id), bytes.NewReader(processed.Thumbnail))
+	if err != nil {
+		cfg.blobs.Delete(r.Context(), blobKey(id))
+		respondWithError(w, http.StatusInternalServerError, "Couldn't store upload", err)
+		return
+	}
+
+	attachment, err := cfg.dbQueries.CreateAttachment(r.Context(), database.CreateAttachmentParams{
+		ID:          id,
+		UserID:      userID,
+		ContentType: processed.ContentType,
+		Width:       int32(processed.Width),
+		Height:      int32(processed.Height),
+		Size:        int32(len(processed.Data)),
+	})
+	if err != nil {
+		cfg.blobs.Delete(r.Context(), blobKey(id))
+		cfg.blobs.Delete(r.Context(), thumbnailKey(id))
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save upload", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, jsonMedia(attachment))
+}
+
+// readUpload reads the "file" field of a multipart form, responding with an
+// error and returning false if that fails or the file is too large.
+func readUpload(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize+1<<20)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't read upload", err)
+		return nil, false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxUploadSize+1))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't read upload", err)
+		return nil, false
+	}
+	if len(data) > maxUploadSize {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Upload is too large", nil)
+		return nil, false
+	}
+	return data, true
+}
+
+func (cfg *apiConfig) getMedia(w http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(w, r, blobKey)
+}
+
+func (cfg *apiConfig) getMediaThumbnail(w http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(w, r, thumbnailKey)
+}
+
+// serveMedia serves a blob to whoever may see the chirp it's attached to.
+// Media that isn't attached yet is only visible to the uploader.
+func (cfg *apiConfig) serveMedia(w http.ResponseWriter, r *http.Request, key func(uuid.UUID) string) {
+	mediaID, err := uuid.Parse(r.PathValue("mediaID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid media ID", err)
+		return
+	}
+
+	attachment, err := cfg.dbQueries.GetAttachment(r.Context(), mediaID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find media", err)
+		return
+	}
+
+	viewer, loggedIn := cfg.viewer(r)
+	if attachment.ChirpID.Valid {
+		_, err = cfg.dbQueries.GetChirp(r.Context(), database.GetChirpParams{
+			ID:            attachment.ChirpID.UUID,
+			IncludeHidden: viewer.IsModerator,
+			ViewerID:      uuid.NullUUID{UUID: viewer.ID, Valid: loggedIn},
+		})
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "Couldn't find media", err)
+			return
+		}
+	} else if !loggedIn || viewer.ID != attachment.UserID {
+		respondWithError(w, http.StatusNotFound, "Couldn't find media", nil)
+		return
+	}
+
+	blob, err := cfg.blobs.Open(r.Context(), key(attachment.ID))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find media", err)
+		return
+	}
+	defer blob.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	http.ServeContent(w, r, "", attachment.CreatedAt, blob)
+}
+
+// validateMedia checks the media a new chirp refers to. Ownership is checked
+// when the media is attached.
+func validateMedia(mediaIn []setMedia) error {
+	if len(mediaIn) > maxMediaPerChirp {
+		return errTooManyMedia
+	}
+	seen := map[uuid.UUID]bool{}
+	for _, m := range mediaIn {
+		if seen[m.ID] {
+			return errDuplicateMedia
+		}
+		seen[m.ID] = true
+		if chirptext.Length(m.AltText) > maxAltTextLength {
+			return errAltTextTooLong
+		}
+	}
+	return nil
+}
+
+var (
+	errTooManyMedia   = errors.New("A chirp can have at most 4 images")
+	errDuplicateMedia = errors.New("The same image is attached twice")
+	errAltTextTooLong = errors.New("Alt text is too long")
+)
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/auth"
+	"github.com/hugermuger/chirpy/internal/database"
+)
+
+func (cfg *apiConfig) votePoll(w http.ResponseWriter, r *http.Request) {
+	type setVote struct {
+		OptionID uuid.UUID `json:"option_id"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get Bearer Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	voteIn := setVote{}
+	err = decoder.Decode(&voteIn)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	viewerID := uuid.NullUUID{UUID: userID, Valid: true}
+	chirp, err := cfg.dbQueries.GetChirp(r.Context(), database.GetChirpParams{
+		ID:       chirpID,
+		ViewerID: viewerID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp", err)
+		return
+	}
+
+	polls, err := cfg.dbQueries.GetPolls(r.Context(), []uuid.UUID{chirp.ID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get poll", err)
+		return
+	}
+	if len(polls) == 0 {
+		respondWithError(w, http.StatusNotFound, "Chirp has no poll", nil)
+		return
+	}
+	if !polls[0].ClosesAt.After(time.Now()) {
+		respondWithError(w, http.StatusConflict, "Poll is closed", nil)
+		return
+	}
+
+	options, err := cfg.dbQueries.GetPollOptions(r.Context(), []uuid.UUID{chirp.ID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get poll", err)
+		return
+	}
+	valid := false
+	for _, option := range options {
+		valid = valid || option.ID == voteIn.OptionID
+	}
+	if !valid {
+		respondWithError(w, http.StatusBadRequest, "Unknown poll option", nil)
+		return
+	}
+
+	// The primary key on (chirp_id, user_id) makes a second vote a no-op,
+	// even when two requests race.
+	inserted, err := cfg.dbQueries.CreatePollVote(r.Context(), database.CreatePollVoteParams{
+		ChirpID:  chirp.ID,
+		UserID:   userID,
+		OptionID: voteIn.OptionID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record vote", err)
+		return
+	}
+	if inserted == 0 {
+		respondWithError(w, http.StatusConflict, "You already voted", nil)
+		return
+	}
+
+	jsonChirps := []Chirp{jsonChirp(chirp)}
+	err = cfg.expandChirps(r.Context(), viewerID, jsonChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get poll", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, jsonChirps[0])
+}
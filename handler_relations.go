@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/auth"
+	"github.com/hugermuger/chirpy/internal/database"
+)
+
+// A block hides chirps in both directions: the blocked user can't see the
+// blocker's chirps and the blocker no longer sees theirs. It also ends any
+// follows between the two, and neither can follow the other while it lasts.
+// A mute only takes the muted user's chirps out of the muter's feeds; their
+// chirps and profile can still be looked up.
+// Following lets a user see chirps posted for followers only.
+
+var errBlocked = errors.New("there is a block between the users")
+
+func (cfg *apiConfig) blockUser(w http.ResponseWriter, r *http.Request) {
+	cfg.changeRelation(w, r, func(ctx context.Context, self, other uuid.UUID) error {
+		tx, err := cfg.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		q := cfg.dbQueries.WithTx(tx)
+
+		err = q.BlockUser(ctx, database.BlockUserParams{BlockerID: self, BlockedID: other})
+		if err != nil {
+			return err
+		}
+		err = q.DeleteFollowsBetween(ctx, database.DeleteFollowsBetweenParams{UserA: self, UserB: other})
+		if err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+func (cfg *apiConfig) unblockUser(w http.ResponseWriter, r *http.Request) {
+	cfg.changeRelation(w, r, func(ctx context.Context, self, other uuid.UUID) error {
+		return cfg.dbQueries.UnblockUser(ctx, database.UnblockUserParams{BlockerID: self, BlockedID: other})
+	})
+}
+
+func (cfg *apiConfig) muteUser(w http.ResponseWriter, r *http.Request) {
+	cfg.changeRelation(w, r, func(ctx context.Context, self, other uuid.UUID) error {
+		return cfg.dbQueries.MuteUser(ctx, database.MuteUserParams{MuterID: self, MutedID: other})
+	})
+}
+
+func (cfg *apiConfig) unmuteUser(w http.ResponseWriter, r *http.Request) {
+	cfg.changeRelation(w, r, func(ctx context.Context, self, other uuid.UUID) error {
+		return cfg.dbQueries.UnmuteUser(ctx, database.UnmuteUserParams{MuterID: self, MutedID: other})
+	})
+}
+
+func (cfg *apiConfig) followUser(w http.ResponseWriter, r *http.Request) {
+	cfg.changeRelation(w, r, func(ctx context.Context, self, other uuid.UUID) error {
+		blocked, err := cfg.dbQueries.HasBlockBetween(ctx, database.HasBlockBetweenParams{UserA: self, UserB: other})
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return errBlocked
+		}
+
+		followed, err := cfg.dbQueries.FollowUser(ctx, database.FollowUserParams{FollowerID: self, FolloweeID: other})
+		if err == nil && followed > 0 {
+			cfg.publishFollow(self, other)
+		}
+		return err
+	})
+}
+
+func (cfg *apiConfig) unfollowUser(w http.ResponseWriter, r *http.Request) {
+	cfg.changeRelation(w, r, func(ctx context.Context, self, other uuid.UUID) error {
+		return cfg.dbQueries.UnfollowUser(ctx, database.UnfollowUserParams{FollowerID: self, FolloweeID: other})
+	})
+}
+
+// changeRelation authenticates the caller, checks the target user in the
+// path and applies change between the two.
+func (cfg *apiConfig) changeRelation(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, self, other uuid.UUID) error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	otherID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	if otherID == userID {
+		respondWithError(w, http.StatusBadRequest, "Can't block, mute or follow yourself", nil)
+		return
+	}
+
+	other, err := cfg.dbQueries.GetUserByID(r.Context(), otherID)
+	if err != nil || other.DeletedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+
+	err = change(r.Context(), userID, other.ID)
+	if errors.Is(err, errBlocked) {
+		respondWithError(w, http.StatusForbidden, "You can't follow this user", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update user relation", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/auth"
+	"github.com/hugermuger/chirpy/internal/database"
+)
+
+// reportReasons are the categories users can pick from. reasonAutoFlag is
+// reserved for reports filed by the server itself.
+var reportReasons = []string{"spam", "harassment", "hate", "violence", "sexual", "misinformation", "other"}
+
+const reasonAutoFlag = "auto_flag"
+
+const defaultSuspension = 7 * 24 * time.Hour
+
+type Report struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ChirpID    *uuid.UUID `json:"chirp_id"`
+	ReporterID *uuid.UUID `json:"reporter_id"`
+	Reason     string     `json:"reason"`
+	Details    string     `json:"details"`
+	Status     string     `json:"status"`
+	ChirpBody  string     `json:"chirp_body,omitempty"`
+	AuthorID   *uuid.UUID `json:"author_id,omitempty"`
+}
+
+type ModerationAction struct {
+	ID           uuid.UUID  `json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ModeratorID  *uuid.UUID `json:"moderator_id"`
+	ReportID     *uuid.UUID `json:"report_id"`
+	ChirpID      *uuid.UUID `json:"chirp_id"`
+	TargetUserID *uuid.UUID `json:"target_user_id"`
+	Action       string     `json:"action"`
+	Note         string     `json:"note"`
+}
+
+func (cfg *apiConfig) reportChirp(w http.ResponseWriter, r *http.Request) {
+	type setReport struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get Bearer Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	reportIn := setReport{}
+	err = decoder.Decode(&reportIn)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	if !slices.Contains(reportReasons, reportIn.Reason) {
+		respondWithError(w, http.StatusBadRequest, "Unknown report reason", nil)
+		return
+	}
+
+	_, err = cfg.dbQueries.GetChirp(r.Context(), database.GetChirpParams{
+		ID:       chirpID,
+		ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp", err)
+		return
+	}
+
+	report, err := cfg.dbQueries.CreateReport(r.Context(), database.CreateReportParams{
+		ChirpID:    uuid.NullUUID{UUID: chirpID, Valid: true},
+		ReporterID: uuid.NullUUID{UUID: userID, Valid: true},
+		Reason:     reportIn.Reason,
+		Details:    reportIn.Details,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create report", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, jsonReport(report))
+}
+
+func (cfg *apiConfig) listReports(w http.ResponseWriter, r *http.Request) {
+	_, ok := cfg.requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	reports, err := cfg.dbQueries.ListOpenReports(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get reports", err)
+		return
+	}
+
+	jsonReports := []Report{}
+	for _, row := range reports {
+		report := jsonReport(database.Report{
+			ID:         row.ID,
+			CreatedAt:  row.CreatedAt,
+			UpdatedAt:  row.UpdatedAt,
+			ChirpID:    row.ChirpID,
+			ReporterID: row.ReporterID,
+			Reason:     row.Reason,
+			Details:    row.Details,
+			Status:     row.Status,
+		})
+		report.ChirpBody = row.ChirpBody.String
+		report.AuthorID = nullUUID(row.AuthorID)
+		jsonReports = append(jsonReports, report)
+	}
+
+	respondWithJSON(w, http.StatusOK, jsonReports)
+}
+
+// actOnReport resolves an open report. Every decision is written to
+// moderation_actions together with the change it makes; a warning is also
+// added to the author's sanction history.
+func (cfg *apiConfig) actOnReport(w http.ResponseWriter, r *http.Request) {
+	type setAction struct {
+		Action   string `json:"action"`
+		Note     string `json:"note"`
+		Duration string `json:"duration"`
+	}
+
+	moderator, ok := cfg.requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid report ID", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	actionIn := setAction{}
+	err = decoder.Decode(&actionIn)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	suspension := defaultSuspension
+	if actionIn.Duration != "" {
+		suspension, err = time.ParseDuration(actionIn.Duration)
+		if err != nil || suspension <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid duration", err)
+			return
+		}
+	}
+
+	status := "resolved"
+	switch actionIn.Action {
+	case "hide", "delete", "warn", "suspend":
+	case "dismiss":
+		status = "dismissed"
+	default:
+		respondWithError(w, http.StatusBadRequest, "Unknown moderation action", nil)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	report, err := q.GetReport(r.Context(), reportID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find report", err)
+		return
+	}
+	if report.Status != "open" {
+		respondWithError(w, http.StatusConflict, "Report is already resolved", nil)
+		return
+	}
+
+	author := uuid.NullUUID{}
+	chirp := database.Chirp{}
+	if report.ChirpID.Valid {
+		found, err := q.GetChirpForReview(r.Context(), report.ChirpID.UUID)
+		if err == nil {
+			chirp = found
+			author = uuid.NullUUID{UUID: chirp.UserID, Valid: true}
+		}
+	}
+	if !author.Valid && actionIn.Action != "dismiss" {
+		respondWithError(w, http.StatusConflict, "The reported chirp no longer exists", nil)
+		return
+	}
+
+	// Resolving the report first locks it, so a second moderator acting on
+	// it at the same time waits here and then finds it already resolved.
+	resolved, err := q.ResolveReport(r.Context(), database.ResolveReportParams{
+		Status: status,
+		ID:     report.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't resolve report", err)
+		return
+	}
+	if resolved == 0 {
+		respondWithError(w, http.StatusConflict, "Report is already resolved", nil)
+		return
+	}
+
+	approved := false
+	switch actionIn.Action {
+	case "dismiss", "warn":
+		// A chirp held back by the spam scorer is fine after all.
+		if chirp.Status == "pending" {
+			chirp, err = q.ApproveChirp(r.Context(), chirp.ID)
+			approved = err == nil
+		}
+		if err == nil && actionIn.Action == "warn" {
+			err = cfg.warnUser(r.Context(), q, author.UUID, uuid.NullUUID{UUID: moderator.ID, Valid: true}, actionIn.Note)
+		}
+	case "hide":
+		err = q.HideChirp(r.Context(), report.ChirpID.UUID)
+	case "delete":
+		err = q.DeleteChirp(r.Context(), report.ChirpID.UUID)
+	case "suspend":
+		err = cfg.suspendUser(r.Context(), q, author.UUID, uuid.NullUUID{UUID: moderator.ID, Valid: true}, time.Now().Add(suspension), actionIn.Note)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't apply moderation action", err)
+		return
+	}
+
+	action, err := q.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		ModeratorID:  uuid.NullUUID{UUID: moderator.ID, Valid: true},
+		ReportID:     uuid.NullUUID{UUID: report.ID, Valid: true},
+		ChirpID:      report.ChirpID,
+		TargetUserID: author,
+		Action:       actionIn.Action,
+		Note:         actionIn.Note,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record moderation action", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't commit moderation action", err)
+		return
+	}
+	removed := (actionIn.Action == "hide" || actionIn.Action == "delete") && chirp.Status == "published"
+	if removed || approved {
+		// If the author can't be looked up, keep the event to their streams
+		// in case they're shadow-banned.
+		chirpAuthor, err := cfg.dbQueries.GetUserByID(r.Context(), chirp.UserID)
+		authorOnly := err != nil || chirpAuthor.ShadowBanned
+		if removed {
+			cfg.publishDeletion(chirp, authorOnly)
+		} else {
+			cfg.queueLinkPreviews(chirp.Body)
+			cfg.publishChirp(r.Context(), chirp, authorOnly)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, jsonModerationAction(action))
+}
+
+func (cfg *apiConfig) listModerationActions(w http.ResponseWriter, r *http.Request) {
+	_, ok := cfg.requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	actions, err := cfg.dbQueries.ListModerationActions(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get moderation actions", err)
+		return
+	}
+
+	jsonActions := []ModerationAction{}
+	for _, action := range actions {
+		jsonActions = append(jsonActions, jsonModerationAction(action))
+	}
+
+	respondWithJSON(w, http.StatusOK, jsonActions)
+}
+
+func jsonReport(report database.Report) Report {
+	return Report{
+		ID:         report.ID,
+		CreatedAt:  report.CreatedAt,
+		UpdatedAt:  report.UpdatedAt,
+		ChirpID:    nullUUID(report.ChirpID),
+		ReporterID: nullUUID(report.ReporterID),
+		Reason:     report.Reason,
+		Details:    report.Details,
+		Status:     report.Status,
+	}
+}
+
+func jsonModerationAction(action database.ModerationAction) ModerationAction {
+	return ModerationAction{
+		ID:           action.ID,
+		CreatedAt:    action.CreatedAt,
+		ModeratorID:  nullUUID(action.ModeratorID),
+		ReportID:     nullUUID(action.ReportID),
+		ChirpID:      nullUUID(action.ChirpID),
+		TargetUserID: nullUUID(action.TargetUserID),
+		Action:       action.Action,
+		Note:         action.Note,
+	}
+}
+
+func nullUUID(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
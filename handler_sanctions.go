@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/database"
+)
+
+type UserSanctions struct {
+	UserID           uuid.UUID      `json:"user_id"`
+	SuspendedUntil   *time.Time     `json:"suspended_until"`
+	SuspensionReason string         `json:"suspension_reason"`
+	ShadowBanned     bool           `json:"shadow_banned"`
+	ShadowBanReason  string         `json:"shadow_ban_reason"`
+	History          []UserSanction `json:"history"`
+}
+
+type UserSanction struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ModeratorID *uuid.UUID `json:"moderator_id"`
+	Action      string     `json:"action"`
+	Reason      string     `json:"reason"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+type setSanction struct {
+	Duration string `json:"duration"`
+	Reason   string `json:"reason"`
+}
+
+func (cfg *apiConfig) suspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.applySanction(w, r, func(ctx context.Context, q *database.Queries, userID, moderatorID uuid.UUID, in setSanction) (int, string) {
+		duration := defaultSuspension
+		if in.Duration != "" {
+			var err error
+			duration, err = time.ParseDuration(in.Duration)
+			if err != nil || duration <= 0 {
+				return http.StatusBadRequest, "Invalid duration"
+			}
+		}
+		err := cfg.suspendUser(ctx, q, userID, uuid.NullUUID{UUID: moderatorID, Valid: true}, time.Now().Add(duration), in.Reason)
+		if err != nil {
+			return http.StatusInternalServerError, "Couldn't suspend user"
+		}
+		return 0, ""
+	})
+}
+
+func (cfg *apiConfig) unsuspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.applySanction(w, r, func(ctx context.Context, q *database.Queries, userID, moderatorID uuid.UUID, in setSanction) (int, string) {
+		err := cfg.unsuspendUser(ctx, q, userID, uuid.NullUUID{UUID: moderatorID, Valid: true}, in.Reason)
+		if err != nil {
+			return http.StatusInternalServerError, "Couldn't lift suspension"
+		}
+		return 0, ""
+	})
+}
+
+func (cfg *apiConfig) shadowBanHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.applySanction(w, r, func(ctx context.Context, q *database.Queries, userID, moderatorID uuid.UUID, in setSanction) (int, string) {
+		err := cfg.setShadowBan(ctx, q, userID, uuid.NullUUID{UUID: moderatorID, Valid: true}, true, in.Reason)
+		if err != nil {
+			return http.StatusInternalServerError, "Couldn't shadow-ban user"
+		}
+		return 0, ""
+	})
+}
+
+func (cfg *apiConfig) liftShadowBanHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.applySanction(w, r, func(ctx context.Context, q *database.Queries, userID, moderatorID uuid.UUID, in setSanction) (int, string) {
+		err := cfg.setShadowBan(ctx, q, userID, uuid.NullUUID{UUID: moderatorID, Valid: true}, false, in.Reason)
+		if err != nil {
+			return http.StatusInternalServerError, "Couldn't lift shadow-ban"
+		}
+		return 0, ""
+	})
+}
+
+// applySanction does the shared work of the sanction endpoints: it checks the
+// caller is a moderator, decodes the optional body and runs apply in a
+// transaction. apply returns a non-zero status and message to abort.
+func (cfg *apiConfig) applySanction(w http.ResponseWriter, r *http.Request, apply func(ctx context.Context, q *database.Queries, userID, moderatorID uuid.UUID, in setSanction) (int, string)) {
+	moderator, ok := cfg.requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	sanctionIn := setSanction{}
+	err = json.NewDecoder(r.Body).Decode(&sanctionIn)
+	if err != nil && err != io.EOF {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	_, err = cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+
+	code, msg := apply(r.Context(), cfg.dbQueries.WithTx(tx), userID, moderator.ID, sanctionIn)
+	if code != 0 {
+		respondWithError(w, code, msg, nil)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't commit sanction", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) getUserSanctions(w http.ResponseWriter, r *http.Request) {
+	_, ok := cfg.requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+
+	sanctions, err := cfg.dbQueries.GetUserSanctions(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get sanctions", err)
+		return
+	}
+
+	result := UserSanctions{
+		UserID:           user.ID,
+		SuspensionReason: user.SuspensionReason,
+		ShadowBanned:     user.ShadowBanned,
+		ShadowBanReason:  user.ShadowBanReason,
+		History:          []UserSanction{},
+	}
+	if isSuspended(user) {
+		result.SuspendedUntil = &user.SuspendedUntil.Time
+	}
+
+	for _, sanction := range sanctions {
+		entry := UserSanction{
+			ID:          sanction.ID,
+			CreatedAt:   sanction.CreatedAt,
+			ModeratorID: nullUUID(sanction.ModeratorID),
+			Action:      sanction.Action,
+			Reason:      sanction.Reason,
+		}
+		if sanction.ExpiresAt.Valid {
+			entry.ExpiresAt = &sanction.ExpiresAt.Time
+		}
+		result.History = append(result.History, entry)
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/auth"
+	"github.com/hugermuger/chirpy/internal/database"
+)
+
+// listTrash returns the caller's deleted chirps that can still be restored,
+// most recently deleted first.
+func (cfg *apiConfig) listTrash(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get Bearer Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	chirps, err := cfg.dbQueries.GetDeletedChirps(r.Context(), database.GetDeletedChirpsParams{
+		UserID:    userID,
+		DeletedAt: cfg.trashCutoff(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get deleted chirps", err)
+		return
+	}
+
+	jsonChirps := []Chirp{}
+	for _, chirp := range chirps {
+		jsonChirps = append(jsonChirps, jsonChirp(chirp))
+	}
+
+	respondWithJSON(w, http.StatusOK, jsonChirps)
+}
+
+func (cfg *apiConfig) restoreChirp(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get Bearer Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	chirp, err := cfg.dbQueries.RestoreChirp(r.Context(), database.RestoreChirpParams{
+		ID:        chirpID,
+		UserID:    userID,
+		DeletedAt: cfg.trashCutoff(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find deleted chirp", err)
+		return
+	}
+
+	author, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't find user", err)
+		return
+	}
+	cfg.publishChirp(r.Context(), chirp, author.ShadowBanned)
+
+	jsonChirps := []Chirp{jsonChirp(chirp)}
+	err = cfg.expandChirps(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, jsonChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp details", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, jsonChirps[0])
+}
+
+// trashCutoff is the deletion time before which chirps can no longer be
+// restored. The purge job may not have removed them yet.
+func (cfg *apiConfig) trashCutoff() sql.NullTime {
+	return sql.NullTime{Time: time.Now().Add(-cfg.trashRetention), Valid: true}
+}
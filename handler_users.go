@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
@@ -11,13 +14,14 @@ import (
 )
 
 type User struct {
-	ID           uuid.UUID `json:"id"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	Email        string    `json:"email"`
-	Token        string    `json:"token"`
-	RefreshToken string    `json:"refresh_token"`
-	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	ID           uuid.UUID         `json:"id"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+	Email        string            `json:"email"`
+	Token        string            `json:"token"`
+	RefreshToken string            `json:"refresh_token"`
+	IsChirpyRed  bool              `json:"is_chirpy_red"`
+	AvatarURLs   map[string]string `json:"avatar_urls,omitempty"`
 }
 
 type setUser struct {
@@ -34,6 +38,12 @@ func (cfg *apiConfig) addUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = cfg.passwordPolicy.Validate(inputuser.Password, inputuser.Email)
+	if err != nil {
+		respondWithPolicyError(w, err)
+		return
+	}
+
 	password, err := auth.HashPassword(inputuser.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
@@ -82,6 +92,25 @@ func (cfg *apiConfig) loginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isSuspended(user) {
+		respondWithError(w, http.StatusForbidden, "Account suspended until "+user.SuspendedUntil.Time.Format(time.RFC3339), nil)
+		return
+	}
+
+	if user.DeletedAt.Valid {
+		if time.Since(user.DeletedAt.Time) > cfg.deletionGrace {
+			respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", nil)
+			return
+		}
+		user, err = cfg.dbQueries.RestoreUser(r.Context(), user.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't restore user", err)
+			return
+		}
+	}
+
+	cfg.upgradePasswordHash(r.Context(), user, inputuser.Password)
+
 	jsonUser := jsonUser(user)
 
 	token, err := auth.MakeJWT(user.ID, cfg.secret, time.Hour)
@@ -112,7 +141,7 @@ func (cfg *apiConfig) updateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	UserID, err := auth.ValidateJWT(token, cfg.secret)
+	UserID, err := cfg.validateAccessToken(r.Context(), token)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
 		return
@@ -126,6 +155,12 @@ func (cfg *apiConfig) updateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = cfg.passwordPolicy.Validate(inputuser.Password, inputuser.Email)
+	if err != nil {
+		respondWithPolicyError(w, err)
+		return
+	}
+
 	password, err := auth.HashPassword(inputuser.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
@@ -149,6 +184,64 @@ func (cfg *apiConfig) updateUser(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, jsonUser)
 }
 
+// deleteUser marks the account as deleted. Logging in again within the grace
+// period restores it, after that purgeDeletedUsers removes it for good.
+func (cfg *apiConfig) deleteUser(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing Token", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r.Context(), token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	inputuser := setUser{}
+	err = decoder.Decode(&inputuser)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil || user.DeletedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+
+	correct, err := auth.CheckPasswordHash(inputuser.Password, user.HashedPassword)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check password", err)
+		return
+	}
+
+	if !correct {
+		respondWithError(w, http.StatusUnauthorized, "Incorrect password", nil)
+		return
+	}
+
+	err = cfg.dbQueries.SoftDeleteUser(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete user", err)
+		return
+	}
+
+	err = cfg.dbQueries.RevokeUserTokens(r.Context(), database.RevokeUserTokensParams{
+		UpdatedAt: time.Now(),
+		UserID:    user.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke tokens", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (cfg *apiConfig) setUserRed(w http.ResponseWriter, r *http.Request) {
 	type Polka struct {
 		Event string `json:"event"`
@@ -203,5 +296,48 @@ func jsonUser(user database.User) User {
 		Email:       user.Email,
 		Token:       "",
 		IsChirpyRed: user.IsChirpyRed,
+		AvatarURLs:  avatarURLs(user),
+	}
+}
+
+func respondWithPolicyError(w http.ResponseWriter, err error) {
+	type policyResponse struct {
+		Error      string                 `json:"error"`
+		Violations []auth.PolicyViolation `json:"violations"`
+	}
+
+	var policyErr *auth.PolicyError
+	if !errors.As(err, &policyErr) {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't validate password", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusBadRequest, policyResponse{
+		Error:      "Password does not meet requirements",
+		Violations: policyErr.Violations,
+	})
+}
+
+// upgradePasswordHash rehashes the password if the stored hash was made with
+// weaker argon2id parameters than the current ones. Failures are only logged
+// since the login itself already succeeded.
+func (cfg *apiConfig) upgradePasswordHash(ctx context.Context, user database.User, password string) {
+	weaker, err := auth.NeedsRehash(user.HashedPassword)
+	if err != nil || !weaker {
+		return
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		log.Printf("Couldn't rehash password for user %s: %s", user.ID, err)
+		return
+	}
+
+	err = cfg.dbQueries.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+		HashedPassword: hash,
+		ID:             user.ID,
+	})
+	if err != nil {
+		log.Printf("Couldn't store rehashed password for user %s: %s", user.ID, err)
 	}
 }
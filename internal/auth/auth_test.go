@@ -1,9 +1,17 @@
 package auth
 
 import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/alexedwards/argon2id"
 	"github.com/google/uuid"
 )
 
@@ -118,3 +126,294 @@ func TestValidateJWT(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateJWTExpiry(t *testing.T) {
+	userID := uuid.New()
+	before := time.Now().Add(time.Hour).Truncate(time.Second)
+	token, _ := MakeJWT(userID, "secret", time.Hour)
+
+	gotUserID, expiresAt, err := ValidateJWTExpiry(token, "secret")
+	if err != nil {
+		t.Fatalf("ValidateJWTExpiry() error = %v", err)
+	}
+	if gotUserID != userID {
+		t.Errorf("ValidateJWTExpiry() gotUserID = %v, want %v", gotUserID, userID)
+	}
+	if expiresAt.Before(before) || expiresAt.After(before.Add(2*time.Second)) {
+		t.Errorf("ValidateJWTExpiry() expiresAt = %v, want about %v", expiresAt, before)
+	}
+
+	expired, _ := MakeJWT(userID, "secret", -time.Minute)
+	if _, _, err := ValidateJWTExpiry(expired, "secret"); err == nil {
+		t.Error("ValidateJWTExpiry() accepted an expired token")
+	}
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	breachedFile := filepath.Join(t.TempDir(), "breached.txt")
+	// SHA-1 of "password123"
+	os.WriteFile(breachedFile, []byte("CBFDAC6008F9CAB4083784CBD1874F76618D2A97:251682\n"), 0o600)
+	breached, err := LoadBreachedPasswords(breachedFile)
+	if err != nil {
+		t.Fatalf("LoadBreachedPasswords() error = %v", err)
+	}
+
+	policy := PasswordPolicy{
+		MinLength:     8,
+		MaxLength:     64,
+		DisallowEmail: true,
+		Breached:      breached,
+	}
+
+	tests := []struct {
+		name      string
+		password  string
+		email     string
+		wantCodes []string
+	}{
+		{
+			name:      "Valid password",
+			password:  "correctPassword123!",
+			email:     "user@example.com",
+			wantCodes: nil,
+		},
+		{
+			name:      "Empty password",
+			password:  "",
+			email:     "user@example.com",
+			wantCodes: []string{PasswordTooShort},
+		},
+		{
+			name:      "Too long",
+			password:  strings.Repeat("a", 65),
+			email:     "user@example.com",
+			wantCodes: []string{PasswordTooLong},
+		},
+		{
+			name:      "Same as email",
+			password:  "User@Example.com",
+			email:     "user@example.com",
+			wantCodes: []string{PasswordIsEmail},
+		},
+		{
+			name:      "Breached password",
+			password:  "password123",
+			email:     "user@example.com",
+			wantCodes: []string{PasswordBreached},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, tt.email)
+			if tt.wantCodes == nil {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			var policyErr *PolicyError
+			if !errors.As(err, &policyErr) {
+				t.Fatalf("Validate() error = %v, want *PolicyError", err)
+			}
+			gotCodes := []string{}
+			for _, v := range policyErr.Violations {
+				gotCodes = append(gotCodes, v.Code)
+			}
+			if !slices.Equal(gotCodes, tt.wantCodes) {
+				t.Errorf("Validate() codes = %v, want %v", gotCodes, tt.wantCodes)
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	defer SetHashParams(HashParams())
+	defer SetRehashTarget(rehashTarget)
+
+	weak := &argon2id.Params{Memory: 8 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	strong := &argon2id.Params{Memory: 16 * 1024, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	// Tuned on a slower machine: less memory, more iterations.
+	tuned := &argon2id.Params{Memory: 12 * 1024, Iterations: 4, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+	SetHashParams(weak)
+	weakHash, _ := HashPassword("correctPassword123!")
+	SetHashParams(strong)
+	strongHash, _ := HashPassword("correctPassword123!")
+	SetHashParams(tuned)
+	tunedHash, _ := HashPassword("correctPassword123!")
+
+	tests := []struct {
+		name    string
+		hash    string
+		params  *argon2id.Params
+		target  *argon2id.Params
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:   "Weaker hash",
+			hash:   weakHash,
+			params: strong,
+			target: strong,
+			want:   true,
+		},
+		{
+			name:   "Current hash",
+			hash:   strongHash,
+			params: strong,
+			target: strong,
+			want:   false,
+		},
+		{
+			name:   "Tuned hash meets target",
+			hash:   tunedHash,
+			params: strong,
+			target: weak,
+			want:   false,
+		},
+		{
+			name:   "Current params below target",
+			hash:   weakHash,
+			params: tuned,
+			target: strong,
+			want:   false,
+		},
+		{
+			name:   "Rehash would lower memory",
+			hash:   strongHash,
+			params: tuned,
+			target: &argon2id.Params{Memory: 8 * 1024, Iterations: 3, SaltLength: 16, KeyLength: 32},
+			want:   false,
+		},
+		{
+			name:    "Invalid hash",
+			hash:    "invalidhash",
+			params:  strong,
+			target:  strong,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetHashParams(tt.params)
+			SetRehashTarget(tt.target)
+			got, err := NeedsRehash(tt.hash)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NeedsRehash() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NeedsRehash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSignedLink(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+	expires := func(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) }
+
+	tests := []struct {
+		name      string
+		resource  string
+		expires   string
+		signature string
+		secret    string
+		wantErr   bool
+	}{
+		{
+			name:      "Valid link",
+			resource:  "export-1",
+			expires:   expires(future),
+			signature: SignLink("export-1", future, "secret"),
+			secret:    "secret",
+			wantErr:   false,
+		},
+		{
+			name:      "Expired link",
+			resource:  "export-1",
+			expires:   expires(past),
+			signature: SignLink("export-1", past, "secret"),
+			secret:    "secret",
+			wantErr:   true,
+		},
+		{
+			name:      "Different resource",
+			resource:  "export-2",
+			expires:   expires(future),
+			signature: SignLink("export-1", future, "secret"),
+			secret:    "secret",
+			wantErr:   true,
+		},
+		{
+			name:      "Extended expiry",
+			resource:  "export-1",
+			expires:   expires(future.Add(time.Hour)),
+			signature: SignLink("export-1", future, "secret"),
+			secret:    "secret",
+			wantErr:   true,
+		},
+		{
+			name:      "Wrong secret",
+			resource:  "export-1",
+			expires:   expires(future),
+			signature: SignLink("export-1", future, "secret"),
+			secret:    "wrong_secret",
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSignedLink(tt.resource, tt.expires, tt.signature, tt.secret)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSignedLink() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetBearerToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		wantToken string
+		wantErr   bool
+	}{
+		{
+			name:      "Valid header",
+			header:    "Bearer abc123",
+			wantToken: "abc123",
+			wantErr:   false,
+		},
+		{
+			name:    "Missing header",
+			header:  "",
+			wantErr: true,
+		},
+		{
+			name:    "Missing token",
+			header:  "Bearer",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := http.Header{}
+			if tt.header != "" {
+				headers.Set("Authorization", tt.header)
+			}
+			gotToken, err := GetBearerToken(headers)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetBearerToken() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotToken != tt.wantToken {
+				t.Errorf("GetBearerToken() = %v, want %v", gotToken, tt.wantToken)
+			}
+		})
+	}
+}
@@ -29,6 +29,13 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	id, _, err := ValidateJWTExpiry(tokenString, tokenSecret)
+	return id, err
+}
+
+// ValidateJWTExpiry is ValidateJWT that also returns when the token expires,
+// for connections that outlive it.
+func ValidateJWTExpiry(tokenString, tokenSecret string) (uuid.UUID, time.Time, error) {
 	claimsStruct := jwt.RegisteredClaims{}
 	token, err := jwt.ParseWithClaims(
 		tokenString,
@@ -36,27 +43,32 @@ func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
 		func(token *jwt.Token) (interface{}, error) { return []byte(tokenSecret), nil },
 	)
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, time.Time{}, err
 	}
 
 	userIDString, err := token.Claims.GetSubject()
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, time.Time{}, err
 	}
 
 	issuer, err := token.Claims.GetIssuer()
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, time.Time{}, err
 	}
 	if issuer != string(TokenTypeAccess) {
-		return uuid.Nil, errors.New("invalid issuer")
+		return uuid.Nil, time.Time{}, errors.New("invalid issuer")
 	}
 
 	id, err := uuid.Parse(userIDString)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("invalid user ID: %w", err)
+		return uuid.Nil, time.Time{}, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	expiresAt, err := token.Claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return uuid.Nil, time.Time{}, errors.New("token has no expiry")
 	}
-	return id, nil
+	return id, expiresAt.Time, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -65,7 +77,11 @@ func GetBearerToken(headers http.Header) (string, error) {
 		return "", fmt.Errorf("No Authorization Header")
 	}
 
-	return strings.Split(header, " ")[1], nil
+	_, token, found := strings.Cut(header, " ")
+	if !found || token == "" {
+		return "", fmt.Errorf("Malformed Authorization Header")
+	}
+	return token, nil
 }
 
 func GetAPIKey(headers http.Header) (string, error) {
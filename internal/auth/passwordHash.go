@@ -1,9 +1,39 @@
 package auth
 
-import "github.com/alexedwards/argon2id"
+import (
+	"time"
+
+	"github.com/alexedwards/argon2id"
+)
+
+// minTuneMemory is the smallest memory cost TuneHashParams will fall back to
+// (19 MiB, the OWASP minimum for argon2id).
+const minTuneMemory = 19 * 1024
+
+var (
+	hashParams   = argon2id.DefaultParams
+	rehashTarget = argon2id.DefaultParams
+)
+
+// SetHashParams changes the argon2id parameters used by HashPassword. Existing
+// hashes keep working because their parameters are encoded in the hash.
+func SetHashParams(params *argon2id.Params) {
+	hashParams = params
+}
+
+func HashParams() *argon2id.Params {
+	return hashParams
+}
+
+// SetRehashTarget sets the parameters NeedsRehash compares stored hashes
+// with. It should be the same configured value on every instance, unlike the
+// hash parameters, which may be tuned per machine.
+func SetRehashTarget(params *argon2id.Params) {
+	rehashTarget = params
+}
 
 func HashPassword(password string) (string, error) {
-	hashPW, err := argon2id.CreateHash(password, argon2id.DefaultParams)
+	hashPW, err := argon2id.CreateHash(password, hashParams)
 	if err != nil {
 		return "", err
 	}
@@ -17,3 +47,71 @@ func CheckPasswordHash(password, hash string) (bool, error) {
 	}
 	return correct, nil
 }
+
+// NeedsRehash reports whether hash was created with weaker parameters than the
+// rehash target and hashing it again with the current parameters would fix
+// that. A rehash never lowers the memory cost of a hash, so instances tuned
+// differently don't keep rewriting each other's hashes. Parallelism is
+// ignored since it follows the CPU count of the machine and doesn't change
+// the cost for an attacker.
+func NeedsRehash(hash string) (bool, error) {
+	params, _, _, err := argon2id.DecodeHash(hash)
+	if err != nil {
+		return false, err
+	}
+	return !meetsTarget(params) &&
+		meetsTarget(hashParams) &&
+		hashParams.Memory >= params.Memory, nil
+}
+
+func meetsTarget(params *argon2id.Params) bool {
+	return params.Memory >= rehashTarget.Memory &&
+		params.Iterations >= rehashTarget.Iterations &&
+		params.SaltLength >= rehashTarget.SaltLength &&
+		params.KeyLength >= rehashTarget.KeyLength
+}
+
+// TuneHashParams benchmarks argon2id on the current machine and returns the
+// strongest parameters that hash a password within target. Memory starts at
+// maxMemory (in KiB) and is only lowered if a single iteration is already too
+// slow; after that iterations are raised until the next step would overshoot.
+func TuneHashParams(target time.Duration, maxMemory uint32, parallelism uint8) (*argon2id.Params, error) {
+	params := &argon2id.Params{
+		Memory:      maxMemory,
+		Iterations:  1,
+		Parallelism: parallelism,
+		SaltLength:  argon2id.DefaultParams.SaltLength,
+		KeyLength:   argon2id.DefaultParams.KeyLength,
+	}
+
+	elapsed, err := timeHash(params)
+	if err != nil {
+		return nil, err
+	}
+	for elapsed > target && params.Memory/2 >= minTuneMemory {
+		params.Memory /= 2
+		elapsed, err = timeHash(params)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		next := *params
+		next.Iterations++
+		elapsed, err = timeHash(&next)
+		if err != nil {
+			return nil, err
+		}
+		if elapsed > target {
+			return params, nil
+		}
+		params = &next
+	}
+}
+
+func timeHash(params *argon2id.Params) (time.Duration, error) {
+	start := time.Now()
+	_, err := argon2id.CreateHash("chirpy-benchmark", params)
+	return time.Since(start), err
+}
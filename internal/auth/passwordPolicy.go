@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// Violation codes reported by PasswordPolicy.Validate.
+const (
+	PasswordTooShort    = "too_short"
+	PasswordTooLong     = "too_long"
+	PasswordIsEmail     = "matches_email"
+	PasswordBreached    = "breached"
+	breachedPrefixChars = 5
+)
+
+// PasswordPolicy describes the rules a new password has to satisfy before it
+// is hashed. MaxLength is counted in bytes because it exists to bound the work
+// argon2 has to do.
+type PasswordPolicy struct {
+	MinLength     int
+	MaxLength     int
+	DisallowEmail bool
+	Breached      *BreachedPasswords
+}
+
+type PolicyViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type PolicyError struct {
+	Violations []PolicyViolation
+}
+
+func (e *PolicyError) Error() string {
+	msgs := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		msgs = append(msgs, v.Message)
+	}
+	return "password rejected: " + strings.Join(msgs, "; ")
+}
+
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:     8,
+		MaxLength:     256,
+		DisallowEmail: true,
+	}
+}
+
+// Validate returns nil if the password is acceptable, or a *PolicyError
+// listing every rule it breaks.
+func (p PasswordPolicy) Validate(password, email string) error {
+	violations := []PolicyViolation{}
+
+	if n := utf8.RuneCountInString(password); n < p.MinLength {
+		violations = append(violations, PolicyViolation{
+			Code:    PasswordTooShort,
+			Message: fmt.Sprintf("password must be at least %d characters", p.MinLength),
+		})
+	}
+
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		violations = append(violations, PolicyViolation{
+			Code:    PasswordTooLong,
+			Message: fmt.Sprintf("password must be at most %d bytes", p.MaxLength),
+		})
+	}
+
+	if p.DisallowEmail && email != "" && strings.EqualFold(strings.TrimSpace(password), strings.TrimSpace(email)) {
+		violations = append(violations, PolicyViolation{
+			Code:    PasswordIsEmail,
+			Message: "password must not be the same as the email",
+		})
+	}
+
+	if p.Breached.Contains(password) {
+		violations = append(violations, PolicyViolation{
+			Code:    PasswordBreached,
+			Message: "password appears in a known data breach",
+		})
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// BreachedPasswords is an offline copy of a breached-password corpus in the
+// Pwned Passwords format: one upper-case SHA-1 hash per line, optionally
+// followed by ":count". Hashes are bucketed by their 5 character prefix the
+// same way the k-anonymity range API serves them.
+type BreachedPasswords struct {
+	ranges map[string]map[string]struct{}
+}
+
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	b := &BreachedPasswords{ranges: map[string]map[string]struct{}{}}
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		hash, _, _ := strings.Cut(entry, ":")
+		hash = strings.ToUpper(hash)
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("%s:%d: invalid SHA-1 hash", path, line)
+		}
+		b.add(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *BreachedPasswords) add(hash string) {
+	prefix, suffix := hash[:breachedPrefixChars], hash[breachedPrefixChars:]
+	bucket, ok := b.ranges[prefix]
+	if !ok {
+		bucket = map[string]struct{}{}
+		b.ranges[prefix] = bucket
+	}
+	bucket[suffix] = struct{}{}
+}
+
+func (b *BreachedPasswords) Contains(password string) bool {
+	if b == nil {
+		return false
+	}
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	bucket, ok := b.ranges[hash[:breachedPrefixChars]]
+	if !ok {
+		return false
+	}
+	_, found := bucket[hash[breachedPrefixChars:]]
+	return found
+}
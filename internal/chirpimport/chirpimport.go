@@ -0,0 +1,139 @@
+// Package chirpimport reads archives of chirps exported from elsewhere. Each
+// row is handed on as it is read; storing it is up to the caller.
+package chirpimport
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+)
+
+// MaxClockSkew is how far in the future an imported timestamp may be, to
+// allow for the clock of the machine that wrote the archive being ahead.
+const MaxClockSkew = 5 * time.Minute
+
+// Row is one chirp of an archive.
+type Row struct {
+	Body      string `json:"body"`
+	CreatedAt string `json:"created_at"`
+}
+
+// ParseCreatedAt parses the created_at of a row, which defaults to now.
+// Chirps are listed by their time, so one from the future would stay at the
+// top of every listing and out of the spam checks' lookback windows.
+func ParseCreatedAt(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return now.UTC(), nil
+	}
+	createdAt, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, errors.New("created_at must be an RFC 3339 timestamp")
+	}
+	if createdAt.After(now.Add(MaxClockSkew)) {
+		return time.Time{}, errors.New("created_at can't be in the future")
+	}
+	return createdAt.UTC(), nil
+}
+
+// Format picks the archive format from an explicit name, falling back
+// to a content type or file name.
+func Format(explicit, hint string) string {
+	if explicit != "" {
+		return strings.ToLower(explicit)
+	}
+
+	hint = strings.ToLower(hint)
+	switch {
+	case strings.Contains(hint, "csv"):
+		return FormatCSV
+	case strings.Contains(hint, "ndjson"), strings.Contains(hint, "jsonl"), strings.Contains(hint, "json"):
+		return FormatJSONL
+	}
+	return ""
+}
+
+// ReadJSONLines reads one JSON object per line; rows are numbered by their
+// line in the file.
+func ReadJSONLines(archive io.Reader, store func(int, Row), fail func(int, error)) error {
+	scanner := bufio.NewScanner(archive)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		in := Row{}
+		err := json.Unmarshal([]byte(text), &in)
+		if err != nil {
+			fail(line, fmt.Errorf("invalid JSON: %w", err))
+			continue
+		}
+		store(line, in)
+	}
+	return scanner.Err()
+}
+
+// ReadCSV expects a header row naming the body and (optionally) created_at
+// columns; rows are numbered by their line in the file.
+func ReadCSV(archive io.Reader, store func(int, Row), fail func(int, error)) error {
+	reader := csv.NewReader(archive)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("couldn't read CSV header: %w", err)
+	}
+
+	bodyCol, createdCol := -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "body":
+			bodyCol = i
+		case "created_at":
+			createdCol = i
+		}
+	}
+	if bodyCol == -1 {
+		return errors.New("CSV header must contain a body column")
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			fail(parseErr.StartLine, parseErr.Err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		line, _ := reader.FieldPos(0)
+		in := Row{}
+		if bodyCol < len(record) {
+			in.Body = record[bodyCol]
+		}
+		if createdCol != -1 && createdCol < len(record) {
+			in.CreatedAt = strings.TrimSpace(record[createdCol])
+		}
+		store(line, in)
+	}
+}
@@ -0,0 +1,37 @@
+package chirpimport
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCreatedAt(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "Missing", in: "", want: now},
+		{name: "Past", in: "2020-05-01T08:30:00Z", want: time.Date(2020, 5, 1, 8, 30, 0, 0, time.UTC)},
+		{name: "Offset", in: "2026-03-01T13:00:00+02:00", want: time.Date(2026, 3, 1, 11, 0, 0, 0, time.UTC)},
+		{name: "Within the clock skew", in: "2026-03-01T12:04:00Z", want: time.Date(2026, 3, 1, 12, 4, 0, 0, time.UTC)},
+		{name: "Future", in: "2026-03-01T12:06:00Z", wantErr: true},
+		{name: "Far future", in: "2099-01-01T00:00:00Z", wantErr: true},
+		{name: "Not a timestamp", in: "yesterday", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCreatedAt(tt.in, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCreatedAt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseCreatedAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package chirptext
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/rivo/uniseg"
+)
+
+// URLLength is how much a link counts against the length limit, no matter
+// how long it actually is.
+const URLLength = 23
+
+// MaxBytes caps the size of a chirp however it counts. Length counts a link
+// or a grapheme with any number of combining marks as one, so the length
+// limit alone doesn't bound the size. The cap is only there against abuse
+// and sits far above what a chirp within any sensible limit needs.
+const MaxBytes = 16 << 10
+
+var urlPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"]+`)
+
+// URL is a link found in a chirp, with its byte offsets into the text.
+type URL struct {
+	URL   string
+	Start int
+	End   int
+}
+
+// URLs returns the links in text. Trailing punctuation that usually ends a
+// sentence rather than the link is left out.
+func URLs(text string) []URL {
+	urls := []URL{}
+	for _, loc := range urlPattern.FindAllStringIndex(text, -1) {
+		start, end := loc[0], loc[1]
+		trimmed := strings.TrimRight(text[start:end], ".,;:!?'")
+		if strings.HasSuffix(trimmed, ")") && !strings.Contains(trimmed, "(") {
+			trimmed = strings.TrimSuffix(trimmed, ")")
+		}
+		end = start + len(trimmed)
+		urls = append(urls, URL{URL: trimmed, Start: start, End: end})
+	}
+	return urls
+}
+
+// Length counts text the way users see it: one per grapheme cluster, so an
+// emoji with modifiers is a single character, and URLLength per link.
+func Length(text string) int {
+	length := 0
+	last := 0
+	for _, url := range URLs(text) {
+		length += uniseg.GraphemeClusterCount(text[last:url.Start]) + URLLength
+		last = url.End
+	}
+	return length + uniseg.GraphemeClusterCount(text[last:])
+}
+
+// Fits reports whether text is within limit as counted by Length, and within
+// MaxBytes.
+func Fits(text string, limit int) bool {
+	return len(text) <= MaxBytes && Length(text) <= limit
+}
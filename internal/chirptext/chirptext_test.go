@@ -0,0 +1,109 @@
+package chirptext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLength(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{
+			name: "ASCII",
+			text: "hello world",
+			want: 11,
+		},
+		{
+			name: "Emoji",
+			text: strings.Repeat("🐦", 50),
+			want: 50,
+		},
+		{
+			name: "Emoji with modifiers",
+			text: "👍🏽👩‍👩‍👧",
+			want: 2,
+		},
+		{
+			name: "Combining accent",
+			text: "e\u0301te\u0301",
+			want: 3,
+		},
+		{
+			name: "URL",
+			text: "read https://example.com/a/very/long/path/that/goes/on/and/on now",
+			want: 5 + URLLength + 4,
+		},
+		{
+			name: "URL followed by punctuation",
+			text: "see https://example.com.",
+			want: 4 + URLLength + 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Length(tt.text); got != tt.want {
+				t.Errorf("Length() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFits(t *testing.T) {
+	longURL := "https://example.com/" + strings.Repeat("a", 180)
+
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  bool
+	}{
+		{
+			name:  "ASCII at the limit",
+			text:  strings.Repeat("a", 140),
+			limit: 140,
+			want:  true,
+		},
+		{
+			name:  "ASCII over the limit",
+			text:  strings.Repeat("a", 141),
+			limit: 140,
+			want:  false,
+		},
+		{
+			name:  "ZWJ family emoji",
+			text:  strings.Repeat("👨‍👩‍👧", 50),
+			limit: 140,
+			want:  true,
+		},
+		{
+			name:  "Skin tone emoji",
+			text:  strings.Repeat("👍🏽", 71),
+			limit: 140,
+			want:  true,
+		},
+		{
+			name:  "Long URLs",
+			text:  longURL + " " + longURL + " " + longURL,
+			limit: 140,
+			want:  true,
+		},
+		{
+			name:  "One grapheme past MaxBytes",
+			text:  "e" + strings.Repeat("\u0301", MaxBytes/2),
+			limit: 140,
+			want:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Fits(tt.text, tt.limit); got != tt.want {
+				t.Errorf("Fits() = %v, want %v (%d bytes, length %d)", got, tt.want, len(tt.text), Length(tt.text))
+			}
+		})
+	}
+}
@@ -7,29 +7,96 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const approveChirp = `-- name: ApproveChirp :one
+UPDATE chirps
+SET
+    updated_at = NOW(),
+    status = 'published'
+WHERE id = $1 AND status = 'pending'
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, visibility, status, scheduled_at, deleted_at
+`
+
+func (q *Queries) ApproveChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, approveChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.Visibility,
+		&i.Status,
+		&i.ScheduledAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const claimDueChirp = `-- name: ClaimDueChirp :one
+SELECT id, created_at, updated_at, body, user_id, hidden_at, visibility, status, scheduled_at, deleted_at FROM chirps
+WHERE status = 'scheduled' AND scheduled_at <= $1
+ORDER BY scheduled_at ASC
+LIMIT 1
+FOR UPDATE SKIP LOCKED
+`
+
+func (q *Queries) ClaimDueChirp(ctx context.Context, scheduledAt sql.NullTime) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, claimDueChirp, scheduledAt)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.Visibility,
+		&i.Status,
+		&i.ScheduledAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, visibility, status, scheduled_at)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, visibility, status, scheduled_at, deleted_at
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body        string
+	UserID      uuid.UUID
+	Visibility  string
+	Status      string
+	ScheduledAt sql.NullTime
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.Visibility,
+		arg.Status,
+		arg.ScheduledAt,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +104,11 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.HiddenAt,
+		&i.Visibility,
+		&i.Status,
+		&i.ScheduledAt,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -50,12 +122,64 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const deleteDraftChirp = `-- name: DeleteDraftChirp :execrows
+DELETE FROM chirps
+WHERE id = $1 AND user_id = $2 AND status IN ('draft', 'scheduled')
+`
+
+type DeleteDraftChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteDraftChirp(ctx context.Context, arg DeleteDraftChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteDraftChirp, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE id = $1
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.hidden_at, chirps.visibility, chirps.status, chirps.scheduled_at, chirps.deleted_at FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = $1 AND chirps.status = 'published' AND chirps.deleted_at IS NULL AND users.deleted_at IS NULL
+    AND chirp_visible(
+        chirps.user_id, chirps.visibility, chirps.hidden_at IS NOT NULL, users.shadow_banned,
+        $2::bool, $3::uuid, FALSE
+    )
+`
+
+type GetChirpParams struct {
+	ID            uuid.UUID
+	IncludeHidden bool
+	ViewerID      uuid.NullUUID
+}
+
+func (q *Queries) GetChirp(ctx context.Context, arg GetChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirp, arg.ID, arg.IncludeHidden, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.Visibility,
+		&i.Status,
+		&i.ScheduledAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getChirpForReview = `-- name: GetChirpForReview :one
+SELECT id, created_at, updated_at, body, user_id, hidden_at, visibility, status, scheduled_at, deleted_at FROM chirps WHERE id = $1 AND deleted_at IS NULL
 `
 
-func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getChirp, id)
+func (q *Queries) GetChirpForReview(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpForReview, id)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -63,16 +187,33 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.HiddenAt,
+		&i.Visibility,
+		&i.Status,
+		&i.ScheduledAt,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps ORDER BY created_at ASC
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.hidden_at, chirps.visibility, chirps.status, chirps.scheduled_at, chirps.deleted_at FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.status = 'published' AND chirps.deleted_at IS NULL AND users.deleted_at IS NULL
+    AND chirp_visible(
+        chirps.user_id, chirps.visibility, chirps.hidden_at IS NOT NULL, users.shadow_banned,
+        $1::bool, $2::uuid, TRUE
+    )
+ORDER BY chirps.created_at ASC
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+type GetChirpsParams struct {
+	IncludeHidden bool
+	ViewerID      uuid.NullUUID
+}
+
+func (q *Queries) GetChirps(ctx context.Context, arg GetChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirps, arg.IncludeHidden, arg.ViewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -86,6 +227,11 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.HiddenAt,
+			&i.Visibility,
+			&i.Status,
+			&i.ScheduledAt,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -101,11 +247,24 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsByUserID = `-- name: GetChirpsByUserID :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE user_id = $1 ORDER BY created_at ASC
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.hidden_at, chirps.visibility, chirps.status, chirps.scheduled_at, chirps.deleted_at FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = $1 AND chirps.status = 'published' AND chirps.deleted_at IS NULL AND users.deleted_at IS NULL
+    AND chirp_visible(
+        chirps.user_id, chirps.visibility, chirps.hidden_at IS NOT NULL, users.shadow_banned,
+        $2::bool, $3::uuid, FALSE
+    )
+ORDER BY chirps.created_at ASC
 `
 
-func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByUserID, userID)
+type GetChirpsByUserIDParams struct {
+	UserID        uuid.UUID
+	IncludeHidden bool
+	ViewerID      uuid.NullUUID
+}
+
+func (q *Queries) GetChirpsByUserID(ctx context.Context, arg GetChirpsByUserIDParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByUserID, arg.UserID, arg.IncludeHidden, arg.ViewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -119,6 +278,11 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.HiddenAt,
+			&i.Visibility,
+			&i.Status,
+			&i.ScheduledAt,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -132,3 +296,373 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 	}
 	return items, nil
 }
+
+const getDeletedChirps = `-- name: GetDeletedChirps :many
+SELECT id, created_at, updated_at, body, user_id, hidden_at, visibility, status, scheduled_at, deleted_at FROM chirps
+WHERE user_id = $1 AND deleted_at > $2
+ORDER BY deleted_at DESC
+`
+
+type GetDeletedChirpsParams struct {
+	UserID    uuid.UUID
+	DeletedAt sql.NullTime
+}
+
+func (q *Queries) GetDeletedChirps(ctx context.Context, arg GetDeletedChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getDeletedChirps, arg.UserID, arg.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.HiddenAt,
+			&i.Visibility,
+			&i.Status,
+			&i.ScheduledAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDraftChirps = `-- name: GetDraftChirps :many
+SELECT id, created_at, updated_at, body, user_id, hidden_at, visibility, status, scheduled_at, deleted_at FROM chirps
+WHERE user_id = $1 AND status IN ('draft', 'scheduled')
+ORDER BY scheduled_at ASC NULLS LAST, created_at ASC
+`
+
+func (q *Queries) GetDraftChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getDraftChirps, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.HiddenAt,
+			&i.Visibility,
+			&i.Status,
+			&i.ScheduledAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRecentChirpsByUser = `-- name: GetRecentChirpsByUser :many
+SELECT body, created_at FROM chirps
+WHERE user_id = $1 AND created_at > $2 AND status IN ('published', 'pending') AND deleted_at IS NULL
+ORDER BY created_at DESC
+LIMIT 100
+`
+
+type GetRecentChirpsByUserParams struct {
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type GetRecentChirpsByUserRow struct {
+	Body      string
+	CreatedAt time.Time
+}
+
+func (q *Queries) GetRecentChirpsByUser(ctx context.Context, arg GetRecentChirpsByUserParams) ([]GetRecentChirpsByUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentChirpsByUser, arg.UserID, arg.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRecentChirpsByUserRow
+	for rows.Next() {
+		var i GetRecentChirpsByUserRow
+		if err := rows.Scan(&i.Body, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const hasRecentDuplicateChirp = `-- name: HasRecentDuplicateChirp :one
+SELECT EXISTS (
+    SELECT 1 FROM chirps
+    WHERE user_id = $1 AND body = $2 AND created_at > $3 AND status IN ('published', 'pending') AND deleted_at IS NULL
+)
+`
+
+type HasRecentDuplicateChirpParams struct {
+	UserID    uuid.UUID
+	Body      string
+	CreatedAt time.Time
+}
+
+func (q *Queries) HasRecentDuplicateChirp(ctx context.Context, arg HasRecentDuplicateChirpParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, hasRecentDuplicateChirp, arg.UserID, arg.Body, arg.CreatedAt)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const hideChirp = `-- name: HideChirp :exec
+UPDATE chirps
+SET
+    updated_at = NOW(),
+    hidden_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) HideChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, hideChirp, id)
+	return err
+}
+
+const importChirp = `-- name: ImportChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, status)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, visibility, status, scheduled_at, deleted_at
+`
+
+type ImportChirpParams struct {
+	CreatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	Status    string
+}
+
+func (q *Queries) ImportChirp(ctx context.Context, arg ImportChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, importChirp,
+		arg.CreatedAt,
+		arg.Body,
+		arg.UserID,
+		arg.Status,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.Visibility,
+		&i.Status,
+		&i.ScheduledAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const lockUserChirps = `-- name: LockUserChirps :exec
+SELECT pg_advisory_xact_lock(hashtextextended($1::uuid::text, 0))
+`
+
+// Held until the transaction ends, so that checking for a duplicate and
+// inserting the chirp can't interleave with another post by the same user.
+func (q *Queries) LockUserChirps(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, lockUserChirps, userID)
+	return err
+}
+
+const publishScheduledChirp = `-- name: PublishScheduledChirp :one
+UPDATE chirps
+SET
+    created_at = NOW(),
+    updated_at = NOW(),
+    status = $1
+WHERE id = $2
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, visibility, status, scheduled_at, deleted_at
+`
+
+type PublishScheduledChirpParams struct {
+	Status string
+	ID     uuid.UUID
+}
+
+func (q *Queries) PublishScheduledChirp(ctx context.Context, arg PublishScheduledChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, publishScheduledChirp, arg.Status, arg.ID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.Visibility,
+		&i.Status,
+		&i.ScheduledAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at IS NOT NULL AND deleted_at < $1
+`
+
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, deletedAt sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedChirps, deletedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const rescheduleChirp = `-- name: RescheduleChirp :exec
+UPDATE chirps
+SET
+    updated_at = NOW(),
+    status = $1,
+    scheduled_at = $2
+WHERE id = $3
+`
+
+type RescheduleChirpParams struct {
+	Status      string
+	ScheduledAt sql.NullTime
+	ID          uuid.UUID
+}
+
+func (q *Queries) RescheduleChirp(ctx context.Context, arg RescheduleChirpParams) error {
+	_, err := q.db.ExecContext(ctx, rescheduleChirp, arg.Status, arg.ScheduledAt, arg.ID)
+	return err
+}
+
+const restoreChirp = `-- name: RestoreChirp :one
+UPDATE chirps
+SET
+    updated_at = NOW(),
+    deleted_at = NULL
+WHERE id = $1 AND user_id = $2 AND deleted_at > $3
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, visibility, status, scheduled_at, deleted_at
+`
+
+type RestoreChirpParams struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	DeletedAt sql.NullTime
+}
+
+func (q *Queries) RestoreChirp(ctx context.Context, arg RestoreChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirp, arg.ID, arg.UserID, arg.DeletedAt)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.Visibility,
+		&i.Status,
+		&i.ScheduledAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const softDeleteChirp = `-- name: SoftDeleteChirp :exec
+UPDATE chirps
+SET
+    updated_at = NOW(),
+    deleted_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) SoftDeleteChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, softDeleteChirp, id)
+	return err
+}
+
+const updateDraftChirp = `-- name: UpdateDraftChirp :one
+UPDATE chirps
+SET
+    updated_at = NOW(),
+    body = $1,
+    visibility = $2,
+    status = $3,
+    scheduled_at = $4
+WHERE id = $5 AND user_id = $6 AND status IN ('draft', 'scheduled')
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, visibility, status, scheduled_at, deleted_at
+`
+
+type UpdateDraftChirpParams struct {
+	Body        string
+	Visibility  string
+	Status      string
+	ScheduledAt sql.NullTime
+	ID          uuid.UUID
+	UserID      uuid.UUID
+}
+
+func (q *Queries) UpdateDraftChirp(ctx context.Context, arg UpdateDraftChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateDraftChirp,
+		arg.Body,
+		arg.Visibility,
+		arg.Status,
+		arg.ScheduledAt,
+		arg.ID,
+		arg.UserID,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.Visibility,
+		&i.Status,
+		&i.ScheduledAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
@@ -62,6 +62,40 @@ func (q *Queries) GetToken(ctx context.Context, id string) (RefreshToken, error)
 	return i, err
 }
 
+const getTokensByUserID = `-- name: GetTokensByUserID :many
+SELECT id, created_at, updated_at, user_id, expires_at, revoked_at FROM refresh_tokens WHERE user_id = $1 ORDER BY created_at ASC
+`
+
+func (q *Queries) GetTokensByUserID(ctx context.Context, userID uuid.UUID) ([]RefreshToken, error) {
+	rows, err := q.db.QueryContext(ctx, getTokensByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RefreshToken
+	for rows.Next() {
+		var i RefreshToken
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const markTokenRevoked = `-- name: MarkTokenRevoked :exec
 UPDATE refresh_tokens
 SET
@@ -79,3 +113,21 @@ func (q *Queries) MarkTokenRevoked(ctx context.Context, arg MarkTokenRevokedPara
 	_, err := q.db.ExecContext(ctx, markTokenRevoked, arg.UpdatedAt, arg.ID)
 	return err
 }
+
+const revokeUserTokens = `-- name: RevokeUserTokens :exec
+UPDATE refresh_tokens
+SET
+    updated_at = $1,
+    revoked_at = $1
+WHERE user_id = $2 AND revoked_at IS NULL
+`
+
+type RevokeUserTokensParams struct {
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+}
+
+func (q *Queries) RevokeUserTokens(ctx context.Context, arg RevokeUserTokensParams) error {
+	_, err := q.db.ExecContext(ctx, revokeUserTokens, arg.UpdatedAt, arg.UserID)
+	return err
+}
@@ -7,8 +7,11 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createUser = `-- name: CreateUser :one
@@ -20,7 +23,7 @@ VALUES (
     $1,
     $2
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, deleted_at, is_moderator, suspended_until, suspension_reason, shadow_banned, shadow_ban_reason, avatar_hash
 `
 
 type CreateUserParams struct {
@@ -38,12 +41,19 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.DeletedAt,
+		&i.IsModerator,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBanned,
+		&i.ShadowBanReason,
+		&i.AvatarHash,
 	)
 	return i, err
 }
 
 const getUser = `-- name: GetUser :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users WHERE email = $1
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, deleted_at, is_moderator, suspended_until, suspension_reason, shadow_banned, shadow_ban_reason, avatar_hash FROM users WHERE email = $1
 `
 
 func (q *Queries) GetUser(ctx context.Context, email string) (User, error) {
@@ -56,10 +66,61 @@ func (q *Queries) GetUser(ctx context.Context, email string) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.DeletedAt,
+		&i.IsModerator,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBanned,
+		&i.ShadowBanReason,
+		&i.AvatarHash,
 	)
 	return i, err
 }
 
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, deleted_at, is_moderator, suspended_until, suspension_reason, shadow_banned, shadow_ban_reason, avatar_hash FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.DeletedAt,
+		&i.IsModerator,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBanned,
+		&i.ShadowBanReason,
+		&i.AvatarHash,
+	)
+	return i, err
+}
+
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deleted_at IS NOT NULL AND deleted_at < $1
+    AND NOT (id = ANY($2::uuid[]))
+`
+
+type PurgeDeletedUsersParams struct {
+	DeletedAt time.Time
+	KeepIds   []uuid.UUID
+}
+
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, arg PurgeDeletedUsersParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedUsers, arg.DeletedAt, pq.Array(arg.KeepIds))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const resetUser = `-- name: ResetUser :exec
 DELETE FROM users
 `
@@ -69,6 +130,54 @@ func (q *Queries) ResetUser(ctx context.Context) error {
 	return err
 }
 
+const restoreUser = `-- name: RestoreUser :one
+UPDATE users
+SET
+    updated_at = NOW(),
+    deleted_at = NULL
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, deleted_at, is_moderator, suspended_until, suspension_reason, shadow_banned, shadow_ban_reason, avatar_hash
+`
+
+func (q *Queries) RestoreUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, restoreUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.DeletedAt,
+		&i.IsModerator,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBanned,
+		&i.ShadowBanReason,
+		&i.AvatarHash,
+	)
+	return i, err
+}
+
+const setUserAvatar = `-- name: SetUserAvatar :exec
+UPDATE users
+SET
+    updated_at = NOW(),
+    avatar_hash = $1
+WHERE id = $2
+`
+
+type SetUserAvatarParams struct {
+	AvatarHash sql.NullString
+	ID         uuid.UUID
+}
+
+func (q *Queries) SetUserAvatar(ctx context.Context, arg SetUserAvatarParams) error {
+	_, err := q.db.ExecContext(ctx, setUserAvatar, arg.AvatarHash, arg.ID)
+	return err
+}
+
 const setUserRed = `-- name: SetUserRed :exec
 UPDATE users
 SET
@@ -86,6 +195,91 @@ func (q *Queries) SetUserRed(ctx context.Context, arg SetUserRedParams) error {
 	return err
 }
 
+const setUserModerator = `-- name: SetUserModerator :exec
+UPDATE users
+SET
+    updated_at = NOW(),
+    is_moderator = $1
+WHERE id = $2
+`
+
+type SetUserModeratorParams struct {
+	IsModerator bool
+	ID          uuid.UUID
+}
+
+func (q *Queries) SetUserModerator(ctx context.Context, arg SetUserModeratorParams) error {
+	_, err := q.db.ExecContext(ctx, setUserModerator, arg.IsModerator, arg.ID)
+	return err
+}
+
+const setUserShadowBan = `-- name: SetUserShadowBan :exec
+UPDATE users
+SET
+    updated_at = NOW(),
+    shadow_banned = $1,
+    shadow_ban_reason = $2
+WHERE id = $3
+`
+
+type SetUserShadowBanParams struct {
+	ShadowBanned    bool
+	ShadowBanReason string
+	ID              uuid.UUID
+}
+
+func (q *Queries) SetUserShadowBan(ctx context.Context, arg SetUserShadowBanParams) error {
+	_, err := q.db.ExecContext(ctx, setUserShadowBan, arg.ShadowBanned, arg.ShadowBanReason, arg.ID)
+	return err
+}
+
+const softDeleteUser = `-- name: SoftDeleteUser :exec
+UPDATE users
+SET
+    updated_at = NOW(),
+    deleted_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) SoftDeleteUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, softDeleteUser, id)
+	return err
+}
+
+const suspendUser = `-- name: SuspendUser :exec
+UPDATE users
+SET
+    updated_at = NOW(),
+    suspended_until = $1,
+    suspension_reason = $2
+WHERE id = $3
+`
+
+type SuspendUserParams struct {
+	SuspendedUntil   sql.NullTime
+	SuspensionReason string
+	ID               uuid.UUID
+}
+
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) error {
+	_, err := q.db.ExecContext(ctx, suspendUser, arg.SuspendedUntil, arg.SuspensionReason, arg.ID)
+	return err
+}
+
+const unsuspendUser = `-- name: UnsuspendUser :exec
+UPDATE users
+SET
+    updated_at = NOW(),
+    suspended_until = NULL,
+    suspension_reason = ''
+WHERE id = $1
+`
+
+func (q *Queries) UnsuspendUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, unsuspendUser, id)
+	return err
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
 SET
@@ -93,7 +287,7 @@ SET
     email = $1,
     hashed_password =$2
 WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, deleted_at, is_moderator, suspended_until, suspension_reason, shadow_banned, shadow_ban_reason, avatar_hash
 `
 
 type UpdateUserParams struct {
@@ -112,6 +306,30 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.DeletedAt,
+		&i.IsModerator,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBanned,
+		&i.ShadowBanReason,
+		&i.AvatarHash,
 	)
 	return i, err
 }
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET
+    hashed_password = $1
+WHERE id = $2
+`
+
+type UpdateUserPasswordParams struct {
+	HashedPassword string
+	ID             uuid.UUID
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.HashedPassword, arg.ID)
+	return err
+}
@@ -0,0 +1,110 @@
+// Package impressions counts how often chirps are served. Counting happens in
+// memory and the totals are handed out in batches, so recording a view never
+// costs a database write of its own.
+package impressions
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Key identifies one counter: a chirp within one time bucket.
+type Key struct {
+	ChirpID uuid.UUID
+	Bucket  time.Time
+}
+
+// Counter collects views until they are drained. Each viewer counts once
+// per chirp and bucket, however often they load it. It is safe for
+// concurrent use.
+type Counter struct {
+	mu     sync.Mutex
+	counts map[Key]int64
+	bucket time.Duration
+	now    func() time.Time
+
+	// seen holds who viewed what in seenBucket, the current bucket. It's
+	// started over when the bucket changes.
+	seen       map[view]struct{}
+	seenBucket time.Time
+}
+
+type view struct {
+	viewer  string
+	chirpID uuid.UUID
+}
+
+// NewCounter returns a Counter that groups views into buckets of the given
+// size, aligned to the zero time.
+func NewCounter(bucket time.Duration) *Counter {
+	return &Counter{
+		counts: map[Key]int64{},
+		bucket: bucket,
+		now:    time.Now,
+		seen:   map[view]struct{}{},
+	}
+}
+
+// Record counts one view by viewer for each chirp in the current bucket,
+// unless viewer has already been counted for it there. viewer is any string
+// that tells viewers apart, such as a user ID or an IP address.
+func (c *Counter) Record(viewer string, chirpIDs ...uuid.UUID) {
+	if len(chirpIDs) == 0 {
+		return
+	}
+	bucket := c.now().Truncate(c.bucket)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !bucket.Equal(c.seenBucket) {
+		c.seen = map[view]struct{}{}
+		c.seenBucket = bucket
+	}
+	for _, id := range chirpIDs {
+		v := view{viewer: viewer, chirpID: id}
+		if _, ok := c.seen[v]; ok {
+			continue
+		}
+		c.seen[v] = struct{}{}
+		c.counts[Key{ChirpID: id, Bucket: bucket}]++
+	}
+}
+
+// Drain returns the views counted so far and starts over.
+func (c *Counter) Drain() map[Key]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := c.counts
+	c.counts = map[Key]int64{}
+	return counts
+}
+
+// Restore adds counts back, for when they couldn't be saved after Drain.
+func (c *Counter) Restore(counts map[Key]int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, n := range counts {
+		c.counts[key] += n
+	}
+}
+
+// Batch holds the views of one bucket.
+type Batch struct {
+	ChirpIDs []uuid.UUID
+	Views    []int64
+}
+
+// ByBucket splits drained counts by bucket, as parallel slices of chirp IDs
+// and view counts.
+func ByBucket(counts map[Key]int64) map[time.Time]Batch {
+	batches := map[time.Time]Batch{}
+	for key, n := range counts {
+		batch := batches[key.Bucket]
+		batch.ChirpIDs = append(batch.ChirpIDs, key.ChirpID)
+		batch.Views = append(batch.Views, n)
+		batches[key.Bucket] = batch
+	}
+	return batches
+}
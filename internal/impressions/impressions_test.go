@@ -0,0 +1,107 @@
+package impressions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCounter(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	start := time.Date(2026, 3, 1, 10, 59, 0, 0, time.UTC)
+	tenOClock := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	elevenOClock := time.Date(2026, 3, 1, 11, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		record  func(c *Counter, now *time.Time)
+		want    map[Key]int64
+		buckets int
+	}{
+		{
+			name:   "nothing recorded",
+			record: func(c *Counter, now *time.Time) {},
+			want:   map[Key]int64{},
+		},
+		{
+			name: "same bucket adds up",
+			record: func(c *Counter, now *time.Time) {
+				c.Record("alice", a, b)
+				c.Record("bob", a)
+			},
+			want: map[Key]int64{
+				{ChirpID: a, Bucket: tenOClock}: 2,
+				{ChirpID: b, Bucket: tenOClock}: 1,
+			},
+			buckets: 1,
+		},
+		{
+			name: "a viewer counts once per bucket",
+			record: func(c *Counter, now *time.Time) {
+				c.Record("alice", a, a)
+				c.Record("alice", a, b)
+			},
+			want: map[Key]int64{
+				{ChirpID: a, Bucket: tenOClock}: 1,
+				{ChirpID: b, Bucket: tenOClock}: 1,
+			},
+			buckets: 1,
+		},
+		{
+			name: "views split across buckets",
+			record: func(c *Counter, now *time.Time) {
+				c.Record("alice", a)
+				*now = now.Add(2 * time.Minute)
+				c.Record("alice", a)
+				c.Record("bob", a)
+			},
+			want: map[Key]int64{
+				{ChirpID: a, Bucket: tenOClock}:    1,
+				{ChirpID: a, Bucket: elevenOClock}: 2,
+			},
+			buckets: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := start
+			c := NewCounter(time.Hour)
+			c.now = func() time.Time { return now }
+
+			tt.record(c, &now)
+			got := c.Drain()
+			if len(got) != len(tt.want) {
+				t.Fatalf("Drain() = %v, want %v", got, tt.want)
+			}
+			for key, n := range tt.want {
+				if got[key] != n {
+					t.Errorf("Drain()[%v] = %d, want %d", key, got[key], n)
+				}
+			}
+			if batches := ByBucket(got); len(batches) != tt.buckets {
+				t.Errorf("ByBucket() has %d buckets, want %d", len(batches), tt.buckets)
+			}
+			if again := c.Drain(); len(again) != 0 {
+				t.Errorf("second Drain() = %v, want nothing", again)
+			}
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	id := uuid.New()
+	c := NewCounter(time.Hour)
+	c.now = func() time.Time { return time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC) }
+	c.Record("alice", id)
+	drained := c.Drain()
+	c.Record("bob", id)
+	c.Restore(drained)
+
+	for key, n := range c.Drain() {
+		if key.ChirpID != id || n != 2 {
+			t.Errorf("after Restore got %v: %d, want %s: 2", key, n, id)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPage = `<!doctype html>
+<html>
+<head>
+  <title>Fallback title</title>
+  <meta property="og:title" content="Chirpy &amp; friends">
+  <meta name="twitter:title" content="Twitter title">
+  <meta name="description" content="  A place
+    to chirp ">
+  <meta property='og:image' content='/img/card.png'>
+  <meta content="Chirpy" property="og:site_name">
+</head>
+<body><meta property="og:description" content="not in the head"></body>
+</html>`
+
+func testServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(testPage))
+	})
+	mux.HandleFunc("/title-only", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>Just a title</title></head></html>"))
+	})
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG"))
+	})
+	mux.HandleFunc("/huge", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head>" + strings.Repeat(" ", 4096) + `<meta property="og:title" content="Too far">`))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html")
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetch(t *testing.T) {
+	server := testServer(t)
+	opts := DefaultOptions()
+	opts.AllowPrivate = true
+	opts.MaxBytes = 1024
+	opts.Timeout = 200 * time.Millisecond
+	fetcher := NewFetcher(opts)
+
+	tests := []struct {
+		name    string
+		path    string
+		want    Preview
+		wantErr error
+	}{
+		{
+			name: "OpenGraph",
+			path: "/page",
+			want: Preview{
+				Title:       "Chirpy & friends",
+				Description: "A place to chirp",
+				ImageURL:    server.URL + "/img/card.png",
+				SiteName:    "Chirpy",
+			},
+		},
+		{
+			name: "Redirect",
+			path: "/redirect",
+			want: Preview{
+				Title:       "Chirpy & friends",
+				Description: "A place to chirp",
+				ImageURL:    server.URL + "/img/card.png",
+				SiteName:    "Chirpy",
+			},
+		},
+		{
+			name: "Title only",
+			path: "/title-only",
+			want: Preview{Title: "Just a title"},
+		},
+		{
+			name:    "Not HTML",
+			path:    "/image.png",
+			wantErr: ErrNotHTML,
+		},
+		{
+			name:    "Not found",
+			path:    "/missing",
+			wantErr: ErrBadResponse,
+		},
+		{
+			name: "Metadata past the size limit",
+			path: "/huge",
+			want: Preview{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fetcher.Fetch(context.Background(), server.URL+tt.path)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Fetch() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			tt.want.URL = server.URL + tt.path
+			if got != tt.want {
+				t.Errorf("Fetch() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	_, err := fetcher.Fetch(context.Background(), server.URL+"/slow")
+	if err == nil {
+		t.Errorf("Fetch() of a slow page succeeded, want a timeout")
+	}
+}
+
+func TestFetchBlocked(t *testing.T) {
+	server := testServer(t)
+	fetcher := NewFetcher(DefaultOptions())
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{name: "Loopback", url: server.URL + "/page"},
+		{name: "Loopback on port 80", url: "http://127.0.0.1/page"},
+		{name: "Localhost name", url: "http://localhost/page"},
+		{name: "Private range", url: "http://10.0.0.1/page"},
+		{name: "Metadata service", url: "http://169.254.169.254/latest/meta-data/"},
+		{name: "Other scheme", url: "file:///etc/passwd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := fetcher.Fetch(context.Background(), tt.url)
+			if !errors.Is(err, ErrBlocked) {
+				t.Errorf("Fetch() error = %v, want ErrBlocked", err)
+			}
+		})
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "93.184.216.34", want: true},
+		{addr: "2606:2800:220:1:248:1893:25c8:1946", want: true},
+		{addr: "127.0.0.1", want: false},
+		{addr: "10.1.2.3", want: false},
+		{addr: "172.16.0.1", want: false},
+		{addr: "192.168.1.1", want: false},
+		{addr: "169.254.169.254", want: false},
+		{addr: "100.64.0.1", want: false},
+		{addr: "0.0.0.0", want: false},
+		{addr: "::1", want: false},
+		{addr: "fd00::1", want: false},
+		{addr: "fe80::1", want: false},
+		{addr: "::ffff:127.0.0.1", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := isPublic(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Errorf("isPublic() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
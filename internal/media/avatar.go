@@ -0,0 +1,52 @@
+package media
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// AvatarSizes are the square sizes every avatar is stored in.
+var AvatarSizes = []int{48, 128, 400}
+
+// Avatar is an uploaded profile picture in each of AvatarSizes. Hash is
+// derived from the content, so URLs built from it never need to change for
+// the same picture and can be cached forever.
+type Avatar struct {
+	ContentType string
+	Hash        string
+	Sizes       map[int][]byte
+}
+
+// ProcessAvatar crops an upload to a square and scales it to AvatarSizes.
+// PNGs stay PNGs to keep transparency and JPEGs stay JPEGs; WebPs become
+// whichever of the two outputType picks.
+func ProcessAvatar(data []byte) (Avatar, error) {
+	contentType, err := Detect(data)
+	if err != nil {
+		return Avatar{}, err
+	}
+	if contentType == GIF {
+		return Avatar{}, ErrUnsupportedType
+	}
+
+	img, err := decode(data, contentType)
+	if err != nil {
+		return Avatar{}, err
+	}
+
+	avatar := Avatar{
+		ContentType: outputType(contentType, img),
+		Sizes:       map[int][]byte{},
+	}
+	hash := sha256.New()
+	for _, size := range AvatarSizes {
+		encoded, err := encode(avatar.ContentType, square(img, size))
+		if err != nil {
+			return Avatar{}, err
+		}
+		avatar.Sizes[size] = encoded
+		hash.Write(encoded)
+	}
+	avatar.Hash = hex.EncodeToString(hash.Sum(nil))[:32]
+	return avatar, nil
+}
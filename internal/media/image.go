@@ -0,0 +1,254 @@
+// Package media turns uploaded images into something safe to serve: the type
+// is checked by content rather than by what the client claims, metadata is
+// dropped by re-encoding, and a thumbnail is made alongside.
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	JPEG = "image/jpeg"
+	PNG  = "image/png"
+	GIF  = "image/gif"
+	WebP = "image/webp"
+)
+
+// ThumbnailSize is the longest side of a thumbnail in pixels.
+const ThumbnailSize = 400
+
+// MaxPixels guards against images that are small on disk but decode into
+// something huge. For animated GIFs it bounds the frames added up as well as
+// the canvas.
+const MaxPixels = 40_000_000
+
+// MaxFrames is the most frames an animated GIF may have.
+const MaxFrames = 500
+
+var (
+	ErrUnsupportedType = errors.New("unsupported image type")
+	ErrTooLarge        = errors.New("image dimensions are too large")
+	ErrTooManyFrames   = errors.New("animation has too many frames")
+)
+
+// Detect returns the image type of data by its magic bytes.
+func Detect(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return JPEG, nil
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return PNG, nil
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return GIF, nil
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return WebP, nil
+	default:
+		return "", ErrUnsupportedType
+	}
+}
+
+// Image is a processed upload. Data and Thumbnail are both of ContentType,
+// which is the type of the upload except for WebP; see outputType.
+type Image struct {
+	ContentType string
+	Width       int
+	Height      int
+	Data        []byte
+	Thumbnail   []byte
+}
+
+// Process validates an upload and re-encodes it. Re-encoding keeps only the
+// pixels, which drops EXIF, XMP and comments; a JPEG's EXIF orientation is
+// applied to the pixels first so the photo doesn't end up sideways. Animated
+// GIFs keep all their frames.
+func Process(data []byte) (Image, error) {
+	contentType, err := Detect(data)
+	if err != nil {
+		return Image{}, err
+	}
+	if contentType == GIF {
+		err = checkSize(data)
+		if err != nil {
+			return Image{}, err
+		}
+		err = checkFrames(data)
+		if err != nil {
+			return Image{}, err
+		}
+		return processGIF(data)
+	}
+
+	img, err := decode(data, contentType)
+	if err != nil {
+		return Image{}, err
+	}
+
+	result := Image{
+		ContentType: outputType(contentType, img),
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+	}
+	result.Data, err = encode(result.ContentType, img)
+	if err != nil {
+		return Image{}, err
+	}
+	result.Thumbnail, err = encode(result.ContentType, thumbnail(img, ThumbnailSize))
+	if err != nil {
+		return Image{}, err
+	}
+	return result, nil
+}
+
+// decode decodes a still image and turns JPEGs upright.
+func decode(data []byte, contentType string) (image.Image, error) {
+	err := checkSize(data)
+	if err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if contentType == JPEG {
+		img = orient(img, jpegOrientation(data))
+	}
+	return img, nil
+}
+
+func checkSize(data []byte) error {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if config.Width*config.Height > MaxPixels {
+		return ErrTooLarge
+	}
+	return nil
+}
+
+// checkFrames walks the blocks of a GIF without decoding them and refuses
+// more than MaxFrames frames, or frames that add up to more than MaxPixels.
+// gif.DecodeAll would decode them all before we got to look. A GIF that is
+// malformed is left to gif.DecodeAll to reject; it can't decode past the
+// point where the walk stopped.
+func checkFrames(data []byte) error {
+	const header = 13
+	if len(data) < header {
+		return nil
+	}
+	pos := header + colorTableSize(data[10])
+
+	frames, pixels := 0, 0
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x21: // Extension: a label, then data sub-blocks.
+			pos = skipSubBlocks(data, pos+2)
+		case 0x2C: // Image descriptor, then the LZW code size and data.
+			if pos+10 > len(data) {
+				return nil
+			}
+			width := int(binary.LittleEndian.Uint16(data[pos+5:]))
+			height := int(binary.LittleEndian.Uint16(data[pos+7:]))
+			frames++
+			pixels += width * height
+			if frames > MaxFrames {
+				return ErrTooManyFrames
+			}
+			if pixels > MaxPixels {
+				return ErrTooLarge
+			}
+			pos += 10 + colorTableSize(data[pos+9])
+			pos = skipSubBlocks(data, pos+1)
+		default: // The trailer, or something gif.DecodeAll will refuse.
+			return nil
+		}
+	}
+	return nil
+}
+
+// colorTableSize is the size in bytes of the colour table announced by the
+// packed field of a screen or image descriptor.
+func colorTableSize(packed byte) int {
+	if packed&0x80 == 0 {
+		return 0
+	}
+	return 3 << (packed&0x07 + 1)
+}
+
+func skipSubBlocks(data []byte, pos int) int {
+	for pos < len(data) {
+		n := int(data[pos])
+		pos++
+		if n == 0 {
+			break
+		}
+		pos += n
+	}
+	return pos
+}
+
+func processGIF(data []byte) (Image, error) {
+	animation, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, err
+	}
+
+	buf := bytes.Buffer{}
+	err = gif.EncodeAll(&buf, animation)
+	if err != nil {
+		return Image{}, err
+	}
+
+	first := animation.Image[0]
+	thumb := bytes.Buffer{}
+	err = gif.Encode(&thumb, thumbnail(first, ThumbnailSize), nil)
+	if err != nil {
+		return Image{}, err
+	}
+
+	return Image{
+		ContentType: GIF,
+		Width:       animation.Config.Width,
+		Height:      animation.Config.Height,
+		Data:        buf.Bytes(),
+		Thumbnail:   thumb.Bytes(),
+	}, nil
+}
+
+// outputType is the type a decoded image is re-encoded as. There is no WebP
+// encoder, so lossy WebPs become JPEGs and lossless ones, which may have
+// transparency, become PNGs.
+func outputType(contentType string, img image.Image) string {
+	if contentType != WebP {
+		return contentType
+	}
+	if _, ok := img.(*image.YCbCr); ok {
+		return JPEG
+	}
+	return PNG
+}
+
+func encode(contentType string, img image.Image) ([]byte, error) {
+	buf := bytes.Buffer{}
+	var err error
+	switch contentType {
+	case JPEG:
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	case PNG:
+		err = png.Encode(&buf, img)
+	case GIF:
+		err = gif.Encode(&buf, img, nil)
+	default:
+		err = ErrUnsupportedType
+	}
+	return buf.Bytes(), err
+}
@@ -0,0 +1,356 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+)
+
+// 1x1 WebPs, as there's no encoder to make them with.
+var (
+	lossyWebP, _    = base64.StdEncoding.DecodeString("UklGRiIAAABXRUJQVlA4IBYAAAAwAQCdASoBAAEADsD+JaQAA3AAAAAA")
+	losslessWebP, _ = base64.StdEncoding.DecodeString("UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA==")
+)
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+// withExif inserts an APP1 segment carrying only an orientation tag right
+// after the SOI marker of a JPEG.
+func withExif(t *testing.T, data []byte, orientation uint16) []byte {
+	t.Helper()
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3)
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1}
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1...)
+	return append(out, data[2:]...)
+}
+
+func TestDetect(t *testing.T) {
+	pngData := bytes.Buffer{}
+	png.Encode(&pngData, testImage(2, 2))
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{name: "JPEG", data: []byte{0xFF, 0xD8, 0xFF, 0xE0}, want: JPEG},
+		{name: "PNG", data: pngData.Bytes(), want: PNG},
+		{name: "GIF", data: []byte("GIF89a......"), want: GIF},
+		{name: "WebP", data: []byte("RIFF\x10\x00\x00\x00WEBPVP8 "), want: WebP},
+		{name: "HTML pretending to be an image", data: []byte("<html><script>"), wantErr: true},
+		{name: "Empty", data: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Detect(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Detect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Detect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcess(t *testing.T) {
+	jpegData := bytes.Buffer{}
+	jpeg.Encode(&jpegData, testImage(800, 600), nil)
+	pngData := bytes.Buffer{}
+	png.Encode(&pngData, testImage(100, 50))
+	gifData := bytes.Buffer{}
+	gif.Encode(&gifData, testImage(30, 20), nil)
+
+	tests := []struct {
+		name       string
+		data       []byte
+		wantType   string
+		wantWidth  int
+		wantHeight int
+		wantThumbW int
+		wantThumbH int
+	}{
+		{
+			name:       "JPEG with EXIF",
+			data:       withExif(t, jpegData.Bytes(), 1),
+			wantType:   JPEG,
+			wantWidth:  800,
+			wantHeight: 600,
+			wantThumbW: 400,
+			wantThumbH: 300,
+		},
+		{
+			name:       "Rotated JPEG",
+			data:       withExif(t, jpegData.Bytes(), 6),
+			wantType:   JPEG,
+			wantWidth:  600,
+			wantHeight: 800,
+			wantThumbW: 300,
+			wantThumbH: 400,
+		},
+		{
+			name:       "Small PNG",
+			data:       pngData.Bytes(),
+			wantType:   PNG,
+			wantWidth:  100,
+			wantHeight: 50,
+			wantThumbW: 100,
+			wantThumbH: 50,
+		},
+		{
+			name:       "GIF",
+			data:       gifData.Bytes(),
+			wantType:   GIF,
+			wantWidth:  30,
+			wantHeight: 20,
+			wantThumbW: 30,
+			wantThumbH: 20,
+		},
+		{
+			name:       "Lossy WebP",
+			data:       lossyWebP,
+			wantType:   JPEG,
+			wantWidth:  1,
+			wantHeight: 1,
+			wantThumbW: 1,
+			wantThumbH: 1,
+		},
+		{
+			name:       "Lossless WebP",
+			data:       losslessWebP,
+			wantType:   PNG,
+			wantWidth:  1,
+			wantHeight: 1,
+			wantThumbW: 1,
+			wantThumbH: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Process(tt.data)
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			if got.ContentType != tt.wantType || got.Width != tt.wantWidth || got.Height != tt.wantHeight {
+				t.Errorf("Process() = %s %dx%d, want %s %dx%d", got.ContentType, got.Width, got.Height, tt.wantType, tt.wantWidth, tt.wantHeight)
+			}
+			if bytes.Contains(got.Data, []byte("Exif")) {
+				t.Errorf("Process() kept the EXIF segment")
+			}
+			thumb, _, err := image.DecodeConfig(bytes.NewReader(got.Thumbnail))
+			if err != nil {
+				t.Fatalf("thumbnail doesn't decode: %v", err)
+			}
+			if thumb.Width != tt.wantThumbW || thumb.Height != tt.wantThumbH {
+				t.Errorf("thumbnail = %dx%d, want %dx%d", thumb.Width, thumb.Height, tt.wantThumbW, tt.wantThumbH)
+			}
+		})
+	}
+}
+
+// gifWithFrames builds a GIF whose canvas and frames claim the given sizes.
+// The frames have no pixel data, which is enough for checks that run before
+// anything is decoded.
+func gifWithFrames(canvas image.Point, frames []image.Point) []byte {
+	data := []byte("GIF89a")
+	data = binary.LittleEndian.AppendUint16(data, uint16(canvas.X))
+	data = binary.LittleEndian.AppendUint16(data, uint16(canvas.Y))
+	data = append(data, 0, 0, 0)
+	for _, frame := range frames {
+		data = append(data, 0x21, 0xF9, 4, 0, 10, 0, 0, 0)
+		data = append(data, 0x2C, 0, 0, 0, 0)
+		data = binary.LittleEndian.AppendUint16(data, uint16(frame.X))
+		data = binary.LittleEndian.AppendUint16(data, uint16(frame.Y))
+		data = append(data, 0x80, 0, 0, 0, 255, 255, 255, 2, 0)
+	}
+	return append(data, 0x3B)
+}
+
+func TestProcessGIFLimits(t *testing.T) {
+	small := gif.GIF{}
+	for range MaxFrames + 1 {
+		small.Image = append(small.Image, image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black}))
+		small.Delay = append(small.Delay, 10)
+	}
+	tooManyFrames := bytes.Buffer{}
+	gif.EncodeAll(&tooManyFrames, &small)
+
+	big := image.Pt(6000, 6000)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{name: "Too many frames", data: tooManyFrames.Bytes(), wantErr: ErrTooManyFrames},
+		{name: "Frames too large in total", data: gifWithFrames(big, []image.Point{big, big}), wantErr: ErrTooLarge},
+		{name: "Canvas too large", data: gifWithFrames(image.Pt(10000, 10000), nil), wantErr: ErrTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Process(tt.data)
+			if err != tt.wantErr {
+				t.Errorf("Process() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResize(t *testing.T) {
+	ycbcr := image.NewYCbCr(image.Rect(0, 0, 40, 20), image.YCbCrSubsampleRatio420)
+	for i := range ycbcr.Y {
+		ycbcr.Y[i] = 255
+	}
+	for i := range ycbcr.Cb {
+		ycbcr.Cb[i], ycbcr.Cr[i] = 128, 128
+	}
+	checker := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := range 4 {
+		for x := range 4 {
+			if (x+y)%2 == 0 {
+				checker.SetRGBA(x, y, color.RGBA{R: 200, G: 100, B: 0, A: 255})
+			}
+		}
+	}
+
+	tests := []struct {
+		name string
+		img  image.Image
+		r    image.Rectangle
+		w, h int
+		want color.RGBA
+	}{
+		{name: "YCbCr", img: ycbcr, r: ycbcr.Bounds(), w: 4, h: 2, want: color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+		{name: "Averages", img: checker, r: checker.Bounds(), w: 2, h: 2, want: color.RGBA{R: 100, G: 50, B: 0, A: 127}},
+		{name: "Crop", img: checker, r: image.Rect(0, 0, 1, 1), w: 3, h: 3, want: color.RGBA{R: 200, G: 100, B: 0, A: 255}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resize(tt.img, tt.r, tt.w, tt.h).(*image.RGBA)
+			if got.Bounds() != image.Rect(0, 0, tt.w, tt.h) {
+				t.Fatalf("resize() bounds = %v", got.Bounds())
+			}
+			for y := range tt.h {
+				for x := range tt.w {
+					if c := got.RGBAAt(x, y); c != tt.want {
+						t.Errorf("resize() pixel %d,%d = %v, want %v", x, y, c, tt.want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestProcessAvatar(t *testing.T) {
+	jpegData := bytes.Buffer{}
+	jpeg.Encode(&jpegData, testImage(300, 200), nil)
+	pngData := bytes.Buffer{}
+	png.Encode(&pngData, testImage(20, 20))
+
+	tests := []struct {
+		name     string
+		data     []byte
+		wantType string
+		wantErr  error
+	}{
+		{name: "Wide JPEG", data: jpegData.Bytes(), wantType: JPEG},
+		{name: "Tiny PNG", data: pngData.Bytes(), wantType: PNG},
+		{name: "Lossy WebP", data: lossyWebP, wantType: JPEG},
+		{name: "Lossless WebP", data: losslessWebP, wantType: PNG},
+		{name: "Not an image", data: []byte("hello"), wantErr: ErrUnsupportedType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ProcessAvatar(tt.data)
+			if err != tt.wantErr {
+				t.Fatalf("ProcessAvatar() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.ContentType != tt.wantType || len(got.Hash) != 32 {
+				t.Errorf("ProcessAvatar() = %s %q, want %s with a hash", got.ContentType, got.Hash, tt.wantType)
+			}
+			for _, size := range AvatarSizes {
+				config, _, err := image.DecodeConfig(bytes.NewReader(got.Sizes[size]))
+				if err != nil {
+					t.Fatalf("size %d doesn't decode: %v", size, err)
+				}
+				if config.Width != size || config.Height != size {
+					t.Errorf("size %d is %dx%d", size, config.Width, config.Height)
+				}
+			}
+		})
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+
+	err = store.Put(ctx, "abc-123", bytes.NewReader([]byte("hello")))
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	blob, err := store.Open(ctx, "abc-123")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	data, _ := io.ReadAll(blob)
+	blob.Close()
+	if string(data) != "hello" {
+		t.Errorf("Open() read %q, want %q", data, "hello")
+	}
+
+	err = store.Delete(ctx, "abc-123")
+	if err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	_, err = store.Open(ctx, "abc-123")
+	if err != ErrNotFound {
+		t.Errorf("Open() after Delete() error = %v, want ErrNotFound", err)
+	}
+
+	err = store.Put(ctx, "../escape", bytes.NewReader(nil))
+	if err == nil {
+		t.Errorf("Put() accepted a key with a path in it")
+	}
+}
@@ -0,0 +1,66 @@
+package media
+
+import (
+	"image"
+	"image/draw"
+)
+
+// thumbnail scales img down so its longer side is at most size. Images that
+// are already small enough are returned as they are.
+func thumbnail(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+
+	tw, th := size, h*size/w
+	if h > w {
+		tw, th = w*size/h, size
+	}
+	return resize(img, b, max(tw, 1), max(th, 1))
+}
+
+// resize scales the part of img within r to exactly tw by th pixels,
+// averaging the source pixels that fall into each destination pixel. The
+// source is converted to RGBA one strip of rows at a time, so a large image
+// is neither read pixel by pixel through At nor copied as a whole.
+func resize(img image.Image, r image.Rectangle, tw, th int) image.Image {
+	w, h := r.Dx(), r.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	strip := image.NewRGBA(image.Rect(0, 0, w, max((h+th-1)/th, 1)))
+	for ty := range th {
+		y0, y1 := ty*h/th, (ty+1)*h/th
+		rows := max(y1-y0, 1)
+		draw.Draw(strip, image.Rect(0, 0, w, rows), img, image.Pt(r.Min.X, r.Min.Y+y0), draw.Src)
+		for tx := range tw {
+			x0, x1 := tx*w/tw, (tx+1)*w/tw
+			x1 = max(x1, x0+1)
+			var sum [4]uint64
+			for y := range rows {
+				row := strip.Pix[y*strip.Stride+x0*4 : y*strip.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += uint64(row[i])
+					sum[1] += uint64(row[i+1])
+					sum[2] += uint64(row[i+2])
+					sum[3] += uint64(row[i+3])
+				}
+			}
+			n := uint64(rows * (x1 - x0))
+			i := dst.PixOffset(tx, ty)
+			for c := range sum {
+				dst.Pix[i+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
+
+// square crops the middle of img to a square and scales it to size.
+func square(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+	return resize(img, image.Rect(x0, y0, x0+side, y0+side), size, size)
+}
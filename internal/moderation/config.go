@@ -0,0 +1,115 @@
+package moderation
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Config describes a pipeline in JSON. Word lists can be given inline or as
+// a file with one word per line; relative files are resolved against the
+// directory of the config file.
+//
+//	{
+//	  "word_lists": [
+//	    {"name": "profanity", "action": "mask", "words": ["kerfuffle"]},
+//	    {"name": "slurs", "action": "reject", "file": "slurs.txt"}
+//	  ],
+//	  "rules": [
+//	    {"name": "crypto-spam", "action": "flag", "pattern": "(?i)free\\s+bitcoin"}
+//	  ]
+//	}
+type Config struct {
+	WordLists []WordListConfig `json:"word_lists"`
+	Rules     []RuleConfig     `json:"rules"`
+}
+
+type WordListConfig struct {
+	Name   string   `json:"name"`
+	Action Action   `json:"action"`
+	Words  []string `json:"words"`
+	File   string   `json:"file"`
+}
+
+type RuleConfig struct {
+	Name    string `json:"name"`
+	Action  Action `json:"action"`
+	Pattern string `json:"pattern"`
+}
+
+// DefaultConfig masks the words Chirpy has always filtered.
+func DefaultConfig() Config {
+	return Config{
+		WordLists: []WordListConfig{{
+			Name:   "profanity",
+			Action: Mask,
+			Words:  []string{"kerfuffle", "sharbert", "fornax"},
+		}},
+	}
+}
+
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config := Config{}
+	err = json.Unmarshal(data, &config)
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for i, list := range config.WordLists {
+		if list.File == "" {
+			continue
+		}
+		if !filepath.IsAbs(list.File) {
+			list.File = filepath.Join(dir, list.File)
+		}
+		words, err := readWordList(list.File)
+		if err != nil {
+			return Config{}, err
+		}
+		config.WordLists[i].Words = append(list.Words, words...)
+	}
+	return config, nil
+}
+
+func (c Config) Filters() ([]Filter, error) {
+	filters := []Filter{}
+	for _, list := range c.WordLists {
+		filters = append(filters, NewWordFilter(list.Name, list.Action, list.Words))
+	}
+	for _, rule := range c.Rules {
+		filter, err := NewRegexFilter(rule.Name, rule.Action, rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+func readWordList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	words := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		words = append(words, word)
+	}
+	return words, scanner.Err()
+}
@@ -0,0 +1,78 @@
+package moderation
+
+import (
+	"regexp"
+	"strings"
+)
+
+// WordFilter matches whole words against a list, after both sides have gone
+// through Normalize.
+type WordFilter struct {
+	name   string
+	action Action
+	words  map[string]struct{}
+}
+
+func NewWordFilter(name string, action Action, words []string) *WordFilter {
+	f := &WordFilter{
+		name:   name,
+		action: action,
+		words:  map[string]struct{}{},
+	}
+	for _, word := range words {
+		word = Normalize(strings.TrimSpace(word))
+		if word != "" {
+			f.words[word] = struct{}{}
+		}
+	}
+	return f
+}
+
+func (f *WordFilter) Apply(text string) (string, []Match) {
+	matches := []Match{}
+	var b strings.Builder
+	last := 0
+	for _, tok := range tokenize(text) {
+		word := text[tok.start:tok.end]
+		if _, ok := f.words[Normalize(word)]; !ok {
+			continue
+		}
+		matches = append(matches, Match{Rule: f.name, Action: f.action, Term: word})
+		if f.action == Mask {
+			b.WriteString(text[last:tok.start])
+			b.WriteString(maskText)
+			last = tok.end
+		}
+	}
+	if last == 0 {
+		return text, matches
+	}
+	b.WriteString(text[last:])
+	return b.String(), matches
+}
+
+// RegexFilter matches a regular expression against the raw text.
+type RegexFilter struct {
+	name    string
+	action  Action
+	pattern *regexp.Regexp
+}
+
+func NewRegexFilter(name string, action Action, pattern string) (*RegexFilter, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &RegexFilter{name: name, action: action, pattern: re}, nil
+}
+
+func (f *RegexFilter) Apply(text string) (string, []Match) {
+	matches := []Match{}
+	for _, found := range f.pattern.FindAllString(text, -1) {
+		matches = append(matches, Match{Rule: f.name, Action: f.action, Term: found})
+	}
+	if f.action == Mask && len(matches) > 0 {
+		text = f.pattern.ReplaceAllLiteralString(text, maskText)
+	}
+	return text, matches
+}
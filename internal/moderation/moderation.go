@@ -0,0 +1,109 @@
+package moderation
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Action is what should happen to a chirp that matched a rule. Actions are
+// ordered by severity so the strongest one wins when several rules match.
+type Action int
+
+const (
+	Allow Action = iota
+	Mask
+	Flag
+	Reject
+)
+
+const maskText = "****"
+
+func (a Action) String() string {
+	switch a {
+	case Allow:
+		return "allow"
+	case Mask:
+		return "mask"
+	case Flag:
+		return "flag"
+	case Reject:
+		return "reject"
+	default:
+		return fmt.Sprintf("Action(%d)", int(a))
+	}
+}
+
+func ParseAction(s string) (Action, error) {
+	switch s {
+	case "allow":
+		return Allow, nil
+	case "mask":
+		return Mask, nil
+	case "flag":
+		return Flag, nil
+	case "reject":
+		return Reject, nil
+	default:
+		return Allow, fmt.Errorf("unknown moderation action %q", s)
+	}
+}
+
+func (a Action) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+func (a *Action) UnmarshalJSON(data []byte) error {
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	*a, err = ParseAction(s)
+	return err
+}
+
+type Match struct {
+	Rule   string `json:"rule"`
+	Action Action `json:"action"`
+	Term   string `json:"term"`
+}
+
+// Filter is a single step of a Pipeline. Apply returns the text for the next
+// step, which only differs from the input if a rule masked something.
+type Filter interface {
+	Apply(text string) (string, []Match)
+}
+
+type Result struct {
+	Text    string
+	Action  Action
+	Matches []Match
+}
+
+// Pipeline runs text through a chain of filters in order.
+type Pipeline struct {
+	filters []Filter
+}
+
+func New(filters ...Filter) *Pipeline {
+	return &Pipeline{filters: filters}
+}
+
+func (p *Pipeline) Add(filters ...Filter) {
+	p.filters = append(p.filters, filters...)
+}
+
+func (p *Pipeline) Moderate(text string) Result {
+	result := Result{Text: text, Action: Allow}
+	for _, filter := range p.filters {
+		var matches []Match
+		result.Text, matches = filter.Apply(result.Text)
+		for _, match := range matches {
+			if match.Action > result.Action {
+				result.Action = match.Action
+			}
+		}
+		result.Matches = append(result.Matches, matches...)
+	}
+	return result
+}
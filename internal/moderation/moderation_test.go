@@ -0,0 +1,77 @@
+package moderation
+
+import "testing"
+
+func TestModerate(t *testing.T) {
+	spam, err := NewRegexFilter("spam", Flag, `(?i)free\s+bitcoin`)
+	if err != nil {
+		t.Fatalf("NewRegexFilter() error = %v", err)
+	}
+	pipeline := New(
+		NewWordFilter("profanity", Mask, []string{"kerfuffle", "sharbert", "fornax"}),
+		NewWordFilter("banned", Reject, []string{"forbidden"}),
+		spam,
+	)
+
+	tests := []struct {
+		name       string
+		text       string
+		wantText   string
+		wantAction Action
+	}{
+		{
+			name:       "Clean text",
+			text:       "This is a kerfuffled opinion",
+			wantText:   "This is a kerfuffled opinion",
+			wantAction: Allow,
+		},
+		{
+			name:       "Plain word",
+			text:       "This is a kerfuffle opinion",
+			wantText:   "This is a **** opinion",
+			wantAction: Mask,
+		},
+		{
+			name:       "Trailing punctuation",
+			text:       "What a Kerfuffle!",
+			wantText:   "What a ****!",
+			wantAction: Mask,
+		},
+		{
+			name:       "Leetspeak",
+			text:       "$harb3rt, again",
+			wantText:   "****, again",
+			wantAction: Mask,
+		},
+		{
+			name:       "Accents and fullwidth",
+			text:       "ｆｏｒｎａｘ and fórnax",
+			wantText:   "**** and ****",
+			wantAction: Mask,
+		},
+		{
+			name:       "Reject wins over mask",
+			text:       "kerfuffle is FORBIDDEN",
+			wantText:   "**** is FORBIDDEN",
+			wantAction: Reject,
+		},
+		{
+			name:       "Regex flag",
+			text:       "Get FREE  bitcoin now",
+			wantText:   "Get FREE  bitcoin now",
+			wantAction: Flag,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := pipeline.Moderate(tt.text)
+			if result.Text != tt.wantText {
+				t.Errorf("Moderate() text = %q, want %q", result.Text, tt.wantText)
+			}
+			if result.Action != tt.wantAction {
+				t.Errorf("Moderate() action = %v, want %v", result.Action, tt.wantAction)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// leetFolds maps common character substitutions back to the letter they
+// stand in for. Only symbols listed here are treated as part of a word.
+var leetFolds = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+	'@': 'a',
+	'$': 's',
+}
+
+type token struct {
+	start, end int
+}
+
+// tokenize splits text into words, returning byte offsets into text. Words
+// are runs of letters, digits, combining marks and leetspeak symbols, so
+// "Kerfuffle!" yields "Kerfuffle" and "$harbert" stays in one piece.
+func tokenize(text string) []token {
+	tokens := []token{}
+	start := -1
+	for i, r := range text {
+		if isWordRune(r) {
+			if start == -1 {
+				start = i
+			}
+			continue
+		}
+		if start != -1 {
+			tokens = append(tokens, token{start, i})
+			start = -1
+		}
+	}
+	if start != -1 {
+		tokens = append(tokens, token{start, len(text)})
+	}
+	return tokens
+}
+
+func isWordRune(r rune) bool {
+	if unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Mn, r) {
+		return true
+	}
+	_, ok := leetFolds[r]
+	return ok
+}
+
+// Normalize folds a word to the form word lists are compared in: compatibility
+// decomposed with accents stripped, lower case, and leetspeak undone.
+func Normalize(word string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(word) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if folded, ok := leetFolds[r]; ok {
+			r = folded
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
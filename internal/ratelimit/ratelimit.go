@@ -0,0 +1,57 @@
+// Package ratelimit implements token-bucket rate limiting. The buckets live
+// in a Store so several server instances can share them; MemoryStore keeps
+// them in process.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Burst requests at once, refilled evenly over Per. "5/1m"
+// allows five requests in a row and one more every twelve seconds after that.
+type Limit struct {
+	Burst int
+	Per   time.Duration
+}
+
+// ParseLimit reads a limit written as "<burst>/<duration>", e.g. "30/1m".
+func ParseLimit(s string) (Limit, error) {
+	burst, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("rate limit %q must look like 30/1m", s)
+	}
+	n, err := strconv.Atoi(burst)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q needs a positive burst", s)
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q needs a positive duration", s)
+	}
+	return Limit{Burst: n, Per: d}, nil
+}
+
+// interval is how long it takes to earn back a single token.
+func (l Limit) interval() time.Duration {
+	return l.Per / time.Duration(l.Burst)
+}
+
+// Result describes a bucket after a request was counted against it.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is how long until the next request would be allowed. It is
+	// zero when Allowed is true.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// Store keeps the buckets. Take counts one request for key against limit.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := Limit{Burst: 3, Per: 3 * time.Second}
+
+	steps := []struct {
+		name          string
+		advance       time.Duration
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+	}{
+		{name: "First", wantAllowed: true, wantRemaining: 2},
+		{name: "Second", wantAllowed: true, wantRemaining: 1},
+		{name: "Third", wantAllowed: true, wantRemaining: 0},
+		{name: "Empty", wantAllowed: false, wantRemaining: 0, wantRetry: time.Second},
+		{name: "Half refilled", advance: 500 * time.Millisecond, wantAllowed: false, wantRemaining: 0, wantRetry: 500 * time.Millisecond},
+		{name: "Refilled one", advance: 500 * time.Millisecond, wantAllowed: true, wantRemaining: 0},
+		{name: "Full again", advance: time.Minute, wantAllowed: true, wantRemaining: 2},
+	}
+
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		result, err := store.Take(context.Background(), "key", limit)
+		if err != nil {
+			t.Fatalf("%s: Take() error = %v", step.name, err)
+		}
+		if result.Allowed != step.wantAllowed || result.Remaining != step.wantRemaining || result.RetryAfter != step.wantRetry {
+			t.Errorf("%s: Take() = %+v, want allowed %v, remaining %d, retry %v", step.name, result, step.wantAllowed, step.wantRemaining, step.wantRetry)
+		}
+	}
+
+	other, _ := store.Take(context.Background(), "other", limit)
+	if !other.Allowed || other.Remaining != 2 {
+		t.Errorf("Take() for another key = %+v, want a fresh bucket", other)
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{in: "30/1m", want: Limit{Burst: 30, Per: time.Minute}},
+		{in: "5/10s", want: Limit{Burst: 5, Per: 10 * time.Second}},
+		{in: "30", wantErr: true},
+		{in: "0/1m", wantErr: true},
+		{in: "5/forever", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseLimit(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLimit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLimit() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseProxies("10.0.0.0/8, 192.168.1.1")
+	if err != nil {
+		t.Fatalf("ParseProxies() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{
+			name:       "Direct",
+			remoteAddr: "203.0.113.7:5123",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "Untrusted peer sends header",
+			remoteAddr: "203.0.113.7:5123",
+			forwarded:  "198.51.100.1",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "Trusted proxy",
+			remoteAddr: "10.1.2.3:80",
+			forwarded:  "198.51.100.1",
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "Chain of trusted proxies",
+			remoteAddr: "10.1.2.3:80",
+			forwarded:  "198.51.100.1, 192.168.1.1",
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "Spoofed hop before the client",
+			remoteAddr: "10.1.2.3:80",
+			forwarded:  "1.2.3.4, 198.51.100.1",
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "IPv6",
+			remoteAddr: "[2001:db8::1]:443",
+			want:       "2001:db8::1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &http.Request{RemoteAddr: tt.remoteAddr, Header: http.Header{}}
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := ClientIP(r, trusted); got != tt.want {
+				t.Errorf("ClientIP() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
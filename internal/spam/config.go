@@ -0,0 +1,105 @@
+package spam
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Config holds the weights and thresholds of the scorer. Every signal adds
+// its weight to the score; the verdict follows from QueueScore and
+// LimitScore.
+//
+//	{
+//	  "rate_window": "10m",
+//	  "rate_free": 5,
+//	  "rate_weight": 1,
+//	  "link_density": 0.3,
+//	  "link_weight": 2,
+//	  "similarity_window": "24h",
+//	  "max_distance": 6,
+//	  "duplicate_weight": 1.5,
+//	  "new_account_age": "24h",
+//	  "new_account_weight": 1,
+//	  "queue_score": 3,
+//	  "limit_score": 5
+//	}
+type Config struct {
+	// RateWindow is how far back posts are counted. The first RateFree posts
+	// in the window are free, each one after that adds RateWeight.
+	RateWindow Duration `json:"rate_window"`
+	RateFree   int      `json:"rate_free"`
+	RateWeight float64  `json:"rate_weight"`
+
+	// LinkDensity is the share of words that may be links before LinkWeight
+	// is added.
+	LinkDensity float64 `json:"link_density"`
+	LinkWeight  float64 `json:"link_weight"`
+
+	// Every recent post within MaxDistance bits of the chirp's simhash adds
+	// DuplicateWeight.
+	SimilarityWindow Duration `json:"similarity_window"`
+	MaxDistance      int      `json:"max_distance"`
+	DuplicateWeight  float64  `json:"duplicate_weight"`
+
+	NewAccountAge    Duration `json:"new_account_age"`
+	NewAccountWeight float64  `json:"new_account_weight"`
+
+	QueueScore float64 `json:"queue_score"`
+	LimitScore float64 `json:"limit_score"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		RateWindow:       Duration(10 * time.Minute),
+		RateFree:         5,
+		RateWeight:       1,
+		LinkDensity:      0.3,
+		LinkWeight:       2,
+		SimilarityWindow: Duration(24 * time.Hour),
+		MaxDistance:      6,
+		DuplicateWeight:  1.5,
+		NewAccountAge:    Duration(24 * time.Hour),
+		NewAccountWeight: 1,
+		QueueScore:       3,
+		LimitScore:       5,
+	}
+}
+
+// LoadConfig reads a config file on top of DefaultConfig, so it only needs to
+// list the values that differ.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config := DefaultConfig()
+	err = json.Unmarshal(data, &config)
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Duration is a time.Duration written as a string like "10m" in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
@@ -0,0 +1,147 @@
+// Package spam scores new chirps on signals that don't depend on their
+// wording: how fast the author posts, how many links a chirp carries, whether
+// it repeats the author's recent chirps and how old the account is.
+package spam
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hugermuger/chirpy/internal/chirptext"
+)
+
+type Verdict int
+
+const (
+	// Allow posts the chirp as usual.
+	Allow Verdict = iota
+	// Queue holds the chirp in the moderation queue until a moderator has
+	// looked at it.
+	Queue
+	// Limit turns the chirp away; the author may try again later.
+	Limit
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Queue:
+		return "queue"
+	case Limit:
+		return "limit"
+	default:
+		return "allow"
+	}
+}
+
+// Post is one of the author's earlier chirps.
+type Post struct {
+	Body      string
+	CreatedAt time.Time
+}
+
+// Input is everything the scorer looks at. Recent should hold the author's
+// chirps within the larger of RateWindow and SimilarityWindow.
+type Input struct {
+	Body       string
+	AccountAge time.Duration
+	Recent     []Post
+	Now        time.Time
+}
+
+// Decision is the outcome of Score. Reasons lists every signal that added to
+// the score, for logging and for moderators. For a Limit verdict, RetryAfter
+// is how long until the same chirp would no longer be limited; it's zero if
+// waiting alone won't help, e.g. when the links alone are enough.
+type Decision struct {
+	Score      float64
+	Verdict    Verdict
+	Reasons    []string
+	RetryAfter time.Duration
+}
+
+func (c Config) Score(in Input) Decision {
+	decision := c.score(in)
+	if decision.Verdict == Limit {
+		decision.RetryAfter = c.retryAfter(in)
+	}
+	return decision
+}
+
+// retryAfter finds the first time one of the signals ages out and the chirp
+// scores below LimitScore: recent posts leave the rate and similarity
+// windows, and the account stops being new.
+func (c Config) retryAfter(in Input) time.Duration {
+	candidates := []time.Time{in.Now.Add(time.Duration(c.NewAccountAge) - in.AccountAge)}
+	for _, post := range in.Recent {
+		candidates = append(candidates,
+			post.CreatedAt.Add(time.Duration(c.RateWindow)),
+			post.CreatedAt.Add(time.Duration(c.SimilarityWindow)),
+		)
+	}
+	slices.SortFunc(candidates, time.Time.Compare)
+
+	for _, at := range candidates {
+		if !at.After(in.Now) {
+			continue
+		}
+		later := in
+		later.Now = at
+		later.AccountAge = in.AccountAge + at.Sub(in.Now)
+		if c.score(later).Score < c.LimitScore {
+			return at.Sub(in.Now)
+		}
+	}
+	return 0
+}
+
+func (c Config) score(in Input) Decision {
+	decision := Decision{Reasons: []string{}}
+	add := func(weight float64, reason string, args ...any) {
+		if weight == 0 {
+			return
+		}
+		decision.Score += weight
+		decision.Reasons = append(decision.Reasons, fmt.Sprintf(reason, args...))
+	}
+
+	rateSince := in.Now.Add(-time.Duration(c.RateWindow))
+	similarSince := in.Now.Add(-time.Duration(c.SimilarityWindow))
+	hash := Simhash(in.Body)
+	posts, similar := 0, 0
+	for _, post := range in.Recent {
+		if post.CreatedAt.After(rateSince) {
+			posts++
+		}
+		if post.CreatedAt.After(similarSince) && Distance(hash, Simhash(post.Body)) <= c.MaxDistance {
+			similar++
+		}
+	}
+
+	if extra := posts - c.RateFree; extra > 0 {
+		add(float64(extra)*c.RateWeight, "%d chirps in %s", posts, time.Duration(c.RateWindow))
+	}
+
+	words := len(strings.Fields(in.Body))
+	links := len(chirptext.URLs(in.Body))
+	if words > 0 && links > 0 && float64(links)/float64(words) > c.LinkDensity {
+		add(c.LinkWeight, "%d of %d words are links", links, words)
+	}
+
+	if similar > 0 {
+		add(float64(similar)*c.DuplicateWeight, "similar to %d recent chirps", similar)
+	}
+
+	if in.AccountAge < time.Duration(c.NewAccountAge) {
+		add(c.NewAccountWeight, "account is %s old", in.AccountAge.Round(time.Minute))
+	}
+
+	switch {
+	case c.LimitScore > 0 && decision.Score >= c.LimitScore:
+		decision.Verdict = Limit
+	case c.QueueScore > 0 && decision.Score >= c.QueueScore:
+		decision.Verdict = Queue
+	}
+	return decision
+}
@@ -0,0 +1,194 @@
+package spam
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSimhash(t *testing.T) {
+	base := "Check out my new blog post about sourdough baking, hydration ratios and the best flour for an open crumb"
+
+	tests := []struct {
+		name    string
+		text    string
+		similar bool
+	}{
+		{
+			name:    "Same text",
+			text:    base,
+			similar: true,
+		},
+		{
+			name:    "Case and punctuation",
+			text:    "check out my NEW blog post about sourdough baking - hydration ratios, and the best flour for an open crumb!!",
+			similar: true,
+		},
+		{
+			name:    "One word changed",
+			text:    "Check out my new blog post about sourdough baking, hydration ratios and the best flour for a open crumb",
+			similar: true,
+		},
+		{
+			name:    "Different text",
+			text:    "The council meeting moved to Thursday because the hall is being repainted this week",
+			similar: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			distance := Distance(Simhash(base), Simhash(tt.text))
+			if got := distance <= DefaultConfig().MaxDistance; got != tt.similar {
+				t.Errorf("Distance() = %d, similar = %v, want %v", distance, got, tt.similar)
+			}
+		})
+	}
+}
+
+func TestScore(t *testing.T) {
+	now := time.Now()
+	config := DefaultConfig()
+
+	burst := []Post{}
+	for i := range 8 {
+		burst = append(burst, Post{Body: "post number " + string(rune('a'+i)), CreatedAt: now.Add(-time.Duration(i) * time.Minute)})
+	}
+
+	tests := []struct {
+		name string
+		in   Input
+		want Verdict
+	}{
+		{
+			name: "Regular chirp",
+			in: Input{
+				Body:       "Had a great time at the park today",
+				AccountAge: 30 * 24 * time.Hour,
+				Now:        now,
+			},
+			want: Allow,
+		},
+		{
+			name: "New account",
+			in: Input{
+				Body:       "Hello everyone, first chirp!",
+				AccountAge: time.Hour,
+				Now:        now,
+			},
+			want: Allow,
+		},
+		{
+			name: "Link dump from new account",
+			in: Input{
+				Body:       "deals https://spam.example/a https://spam.example/b",
+				AccountAge: time.Hour,
+				Now:        now,
+			},
+			want: Queue,
+		},
+		{
+			name: "Burst",
+			in: Input{
+				Body:       "one more thing",
+				AccountAge: 30 * 24 * time.Hour,
+				Recent:     burst,
+				Now:        now,
+			},
+			want: Queue,
+		},
+		{
+			name: "Repeated near-duplicates",
+			in: Input{
+				Body:       "Buy cheap followers now at my shop, best prices guaranteed!",
+				AccountAge: 30 * 24 * time.Hour,
+				Recent: []Post{
+					{Body: "buy cheap followers now at my shop, best prices guaranteed", CreatedAt: now.Add(-time.Hour)},
+					{Body: "Buy cheap followers now at my shop - best prices guaranteed!!", CreatedAt: now.Add(-2 * time.Hour)},
+					{Body: "BUY CHEAP FOLLOWERS NOW AT MY SHOP, BEST PRICES GUARANTEED", CreatedAt: now.Add(-3 * time.Hour)},
+					{Body: "Buy cheap followers now at my shop, best prices guaranteed.", CreatedAt: now.Add(-4 * time.Hour)},
+					{Body: "Buy cheap followers now at my shop, best prices guaranteed", CreatedAt: now.Add(-48 * time.Hour)},
+				},
+				Now: now,
+			},
+			want: Limit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := config.Score(tt.in)
+			if decision.Verdict != tt.want {
+				t.Errorf("Score() verdict = %v (score %.1f, %v), want %v", decision.Verdict, decision.Score, decision.Reasons, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Now()
+	config := DefaultConfig()
+	spam := "Buy cheap followers now at my shop, best prices guaranteed!"
+
+	burst := []Post{}
+	for i := range 10 {
+		burst = append(burst, Post{Body: "post number " + string(rune('a'+i)), CreatedAt: now.Add(-time.Duration(i) * time.Minute)})
+	}
+
+	tests := []struct {
+		name string
+		in   Input
+		want time.Duration
+	}{
+		{
+			name: "Burst ages out of the rate window",
+			in: Input{
+				Body:       "one more thing",
+				AccountAge: 30 * 24 * time.Hour,
+				Recent:     burst,
+				Now:        now,
+			},
+			// 10 posts score 5; the limit is lifted once the one posted 9
+			// minutes ago leaves the 10 minute window.
+			want: time.Minute,
+		},
+		{
+			name: "Near-duplicates age out of the similarity window",
+			in: Input{
+				Body:       spam,
+				AccountAge: 30 * 24 * time.Hour,
+				Recent: []Post{
+					{Body: spam, CreatedAt: now.Add(-time.Hour)},
+					{Body: spam, CreatedAt: now.Add(-2 * time.Hour)},
+					{Body: spam, CreatedAt: now.Add(-3 * time.Hour)},
+					{Body: spam, CreatedAt: now.Add(-4 * time.Hour)},
+				},
+				Now: now,
+			},
+			// Four duplicates score 6; after the oldest leaves, three score
+			// 4.5.
+			want: 20 * time.Hour,
+		},
+		{
+			name: "Waiting doesn't help",
+			in: Input{
+				Body:       "https://spam.example/a https://spam.example/b",
+				AccountAge: 30 * 24 * time.Hour,
+				Now:        now,
+			},
+			want: 0,
+		},
+	}
+
+	config.LinkWeight = config.LimitScore
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := config.Score(tt.in)
+			if decision.Verdict != Limit {
+				t.Fatalf("Score() verdict = %v (score %.1f, %v), want %v", decision.Verdict, decision.Score, decision.Reasons, Limit)
+			}
+			if decision.RetryAfter != tt.want {
+				t.Errorf("Score() RetryAfter = %v, want %v", decision.RetryAfter, tt.want)
+			}
+		})
+	}
+}
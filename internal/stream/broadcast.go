@@ -0,0 +1,19 @@
+package stream
+
+// Broadcaster carries events to the hub of every server instance. With more
+// than one instance, an implementation backed by a shared message bus
+// publishes each event there and feeds what it receives into the local hub.
+type Broadcaster interface {
+	Broadcast(event Event) error
+}
+
+// Local hands events straight to a single hub. It's all a single instance
+// needs.
+type Local struct {
+	Hub *Hub
+}
+
+func (l Local) Broadcast(event Event) error {
+	l.Hub.Publish(event)
+	return nil
+}
@@ -0,0 +1,145 @@
+// Package stream fans chirp events out to live subscribers. The hub keeps the
+// most recent events so a subscriber that reconnects can pick up where it
+// left off, and it cuts off subscribers that fall too far behind instead of
+// letting them hold up everyone else.
+package stream
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TypeChirp  = "chirp"
+	TypeDelete = "delete"
+	// TypeFollow is a notification for RecipientID that AuthorID followed
+	// them.
+	TypeFollow = "follow"
+)
+
+// Event is something that happened to a chirp, or a notification for one
+// user. The author and visibility are there so subscribers can decide
+// whether to show it; Data is the JSON sent to the client.
+type Event struct {
+	ID         uint64
+	Type       string
+	ChirpID    uuid.UUID
+	AuthorID   uuid.UUID
+	Visibility string
+	// AuthorOnly events may only be shown to the author, e.g. chirps of a
+	// shadow-banned user.
+	AuthorOnly bool
+	// RecipientID is set on notifications, which only go to that user.
+	RecipientID uuid.UUID
+	Data        []byte
+}
+
+// Subscription receives the events that pass its filter. C is closed when
+// the subscriber falls behind by more than its buffer or the subscription
+// is closed.
+type Subscription struct {
+	C <-chan Event
+
+	c      chan Event
+	filter func(Event) bool
+	hub    *Hub
+}
+
+// Close stops the subscription. It's safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// Hub is the in-process side of the stream: it assigns event IDs and keeps
+// the subscriptions of this instance.
+type Hub struct {
+	mu      sync.Mutex
+	subs    map[*Subscription]struct{}
+	history []Event
+	next    int
+	size    int
+	lastID  uint64
+	buffer  int
+}
+
+// NewHub returns a hub that keeps the last history events for resuming and
+// lets each subscriber fall behind by at most buffer events.
+//
+// Event IDs start from the current time, so IDs handed out before a restart
+// are older than anything in the new hub's history.
+func NewHub(history, buffer int) *Hub {
+	return &Hub{
+		subs:    map[*Subscription]struct{}{},
+		history: make([]Event, history),
+		lastID:  uint64(time.Now().UnixNano()),
+		buffer:  buffer,
+	}
+}
+
+// Publish assigns the event an ID, keeps it for resuming and sends it to
+// every subscriber whose filter accepts it. It never blocks: a subscriber
+// whose buffer is full is dropped.
+func (h *Hub) Publish(event Event) Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastID++
+	event.ID = h.lastID
+	if len(h.history) > 0 {
+		h.history[h.next] = event
+		h.next = (h.next + 1) % len(h.history)
+		h.size = min(h.size+1, len(h.history))
+	}
+
+	for sub := range h.subs {
+		if !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.c <- event:
+		default:
+			h.remove(sub)
+		}
+	}
+	return event
+}
+
+// Subscribe starts a subscription. With a non-zero lastID it also returns
+// the kept events after that ID which pass the filter. complete is false if
+// events after lastID have already been forgotten, so the subscriber has
+// missed some.
+func (h *Hub) Subscribe(lastID uint64, filter func(Event) bool) (sub *Subscription, missed []Event, complete bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c := make(chan Event, h.buffer)
+	sub = &Subscription{C: c, c: c, filter: filter, hub: h}
+	h.subs[sub] = struct{}{}
+
+	if lastID == 0 {
+		return sub, nil, true
+	}
+
+	// IDs are consecutive, so the kept events are the last h.size IDs.
+	complete = lastID <= h.lastID && h.lastID-lastID <= uint64(h.size)
+	for i := range h.size {
+		event := h.history[(h.next-h.size+i+len(h.history))%len(h.history)]
+		if event.ID > lastID && filter(event) {
+			missed = append(missed, event)
+		}
+	}
+	return sub, missed, complete
+}
+
+// remove must be called with h.mu held.
+func (h *Hub) remove(sub *Subscription) {
+	if _, ok := h.subs[sub]; !ok {
+		return
+	}
+	delete(h.subs, sub)
+	close(sub.c)
+}
@@ -0,0 +1,105 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func all(Event) bool { return true }
+
+func TestPublish(t *testing.T) {
+	hub := NewHub(8, 2)
+	alice, bob := uuid.New(), uuid.New()
+
+	everything, _, _ := hub.Subscribe(0, all)
+	onlyBob, _, _ := hub.Subscribe(0, func(e Event) bool { return e.AuthorID == bob })
+
+	first := hub.Publish(Event{Type: TypeChirp, AuthorID: alice})
+	second := hub.Publish(Event{Type: TypeChirp, AuthorID: bob})
+	if second.ID != first.ID+1 {
+		t.Fatalf("IDs %d and %d aren't consecutive", first.ID, second.ID)
+	}
+
+	if got := <-everything.C; got.ID != first.ID {
+		t.Errorf("first event = %d, want %d", got.ID, first.ID)
+	}
+	if got := <-everything.C; got.ID != second.ID {
+		t.Errorf("second event = %d, want %d", got.ID, second.ID)
+	}
+	if got := <-onlyBob.C; got.AuthorID != bob {
+		t.Errorf("filtered subscriber got an event by %s", got.AuthorID)
+	}
+	if len(onlyBob.C) != 0 {
+		t.Errorf("filtered subscriber has %d more events, want 0", len(onlyBob.C))
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	hub := NewHub(8, 2)
+	slow, _, _ := hub.Subscribe(0, all)
+	fast, _, _ := hub.Subscribe(0, all)
+
+	for range 3 {
+		hub.Publish(Event{Type: TypeChirp})
+		<-fast.C
+	}
+
+	received := 0
+	for range slow.C {
+		received++
+	}
+	if received != 2 {
+		t.Errorf("slow subscriber got %d events before being dropped, want 2", received)
+	}
+
+	hub.Publish(Event{Type: TypeChirp})
+	if _, ok := <-fast.C; !ok {
+		t.Error("fast subscriber was dropped")
+	}
+	slow.Close()
+	fast.Close()
+	if _, ok := <-fast.C; ok {
+		t.Error("channel still open after Close")
+	}
+}
+
+func TestResume(t *testing.T) {
+	hub := NewHub(3, 8)
+	ids := []uint64{}
+	for range 5 {
+		ids = append(ids, hub.Publish(Event{Type: TypeChirp}).ID)
+	}
+
+	tests := []struct {
+		name         string
+		lastID       uint64
+		wantMissed   int
+		wantComplete bool
+	}{
+		{name: "up to date", lastID: ids[4], wantMissed: 0, wantComplete: true},
+		{name: "one behind", lastID: ids[3], wantMissed: 1, wantComplete: true},
+		{name: "oldest kept is next", lastID: ids[1], wantMissed: 3, wantComplete: true},
+		{name: "some forgotten", lastID: ids[0], wantMissed: 3, wantComplete: false},
+		{name: "from before a restart", lastID: 1, wantMissed: 3, wantComplete: false},
+		{name: "from the future", lastID: ids[4] + 10, wantMissed: 0, wantComplete: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, missed, complete := hub.Subscribe(tt.lastID, all)
+			defer sub.Close()
+			if len(missed) != tt.wantMissed {
+				t.Errorf("missed %d events, want %d", len(missed), tt.wantMissed)
+			}
+			if complete != tt.wantComplete {
+				t.Errorf("complete = %v, want %v", complete, tt.wantComplete)
+			}
+			for i := 1; i < len(missed); i++ {
+				if missed[i].ID <= missed[i-1].ID {
+					t.Errorf("missed events out of order: %d after %d", missed[i].ID, missed[i-1].ID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+// Package visibility decides who may see a chirp. The chirp queries use the
+// chirp_visible SQL function (sql/schema/024_chirp_visible.sql); this is the
+// same rule for code that can't ask the database, such as the stream, which
+// checks every event as it passes. Change both together.
+package visibility
+
+import "github.com/google/uuid"
+
+// Chirp is what the rule needs to know about a chirp.
+type Chirp struct {
+	AuthorID     uuid.UUID
+	Visibility   string
+	Hidden       bool
+	ShadowBanned bool
+}
+
+// Viewer is who is looking, with their relations to other users. Blocks
+// holds users blocked in either direction. A zero ID is a logged out viewer.
+type Viewer struct {
+	ID        uuid.NullUUID
+	Moderator bool
+	Follows   map[uuid.UUID]bool
+	Blocks    map[uuid.UUID]bool
+	Mutes     map[uuid.UUID]bool
+}
+
+// CanSee reports whether v may see c. Hidden chirps are shown to moderators
+// only, not even to their author, and chirps of shadow-banned users to
+// moderators and the author. inFeed is set for feeds mixing many authors:
+// they leave out unlisted chirps and muted authors, while looking up a
+// chirp, an author or a bookmark shows unlisted chirps to anyone logged in
+// and ignores mutes.
+func (v Viewer) CanSee(c Chirp, inFeed bool) bool {
+	switch {
+	case c.Hidden && !v.Moderator:
+		return false
+	case v.ID.Valid && c.AuthorID == v.ID.UUID:
+		return true
+	case c.ShadowBanned && !v.Moderator:
+		return false
+	case v.Blocks[c.AuthorID]:
+		return false
+	case inFeed && v.Mutes[c.AuthorID]:
+		return false
+	}
+
+	switch c.Visibility {
+	case "public":
+		return true
+	case "unlisted":
+		return !inFeed && v.ID.Valid
+	case "followers":
+		return v.Follows[c.AuthorID]
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package visibility
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCanSee(t *testing.T) {
+	author, viewerID := uuid.New(), uuid.New()
+	anonymous := Viewer{}
+	viewer := Viewer{ID: uuid.NullUUID{UUID: viewerID, Valid: true}}
+	self := Viewer{ID: uuid.NullUUID{UUID: author, Valid: true}}
+	moderator := Viewer{ID: viewer.ID, Moderator: true}
+	follower := Viewer{ID: viewer.ID, Follows: map[uuid.UUID]bool{author: true}}
+	blocked := Viewer{ID: viewer.ID, Follows: map[uuid.UUID]bool{author: true}, Blocks: map[uuid.UUID]bool{author: true}}
+	muting := Viewer{ID: viewer.ID, Mutes: map[uuid.UUID]bool{author: true}}
+
+	public := Chirp{AuthorID: author, Visibility: "public"}
+	unlisted := Chirp{AuthorID: author, Visibility: "unlisted"}
+	followers := Chirp{AuthorID: author, Visibility: "followers"}
+	private := Chirp{AuthorID: author, Visibility: "private"}
+	hidden := Chirp{AuthorID: author, Visibility: "public", Hidden: true}
+	shadowBanned := Chirp{AuthorID: author, Visibility: "public", ShadowBanned: true}
+
+	tests := []struct {
+		name   string
+		viewer Viewer
+		chirp  Chirp
+		inFeed bool
+		want   bool
+	}{
+		{name: "public in a feed", viewer: anonymous, chirp: public, inFeed: true, want: true},
+		{name: "unlisted in a feed", viewer: viewer, chirp: unlisted, inFeed: true, want: false},
+		{name: "unlisted looked up", viewer: viewer, chirp: unlisted, want: true},
+		{name: "unlisted looked up logged out", viewer: anonymous, chirp: unlisted, want: false},
+		{name: "own unlisted in a feed", viewer: self, chirp: unlisted, inFeed: true, want: true},
+		{name: "followers only by a follower", viewer: follower, chirp: followers, inFeed: true, want: true},
+		{name: "followers only by someone else", viewer: viewer, chirp: followers, want: false},
+		{name: "private by someone else", viewer: follower, chirp: private, want: false},
+		{name: "own private", viewer: self, chirp: private, inFeed: true, want: true},
+		{name: "blocked", viewer: blocked, chirp: followers, want: false},
+		{name: "muted in a feed", viewer: muting, chirp: public, inFeed: true, want: false},
+		{name: "muted looked up", viewer: muting, chirp: public, want: true},
+		{name: "hidden", viewer: viewer, chirp: hidden, want: false},
+		{name: "own hidden", viewer: self, chirp: hidden, want: false},
+		{name: "hidden by a moderator", viewer: moderator, chirp: hidden, inFeed: true, want: true},
+		{name: "shadow-banned", viewer: viewer, chirp: shadowBanned, want: false},
+		{name: "own while shadow-banned", viewer: self, chirp: shadowBanned, inFeed: true, want: true},
+		{name: "shadow-banned by a moderator", viewer: moderator, chirp: shadowBanned, inFeed: true, want: true},
+		{name: "moderator doesn't see private", viewer: moderator, chirp: private, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.viewer.CanSee(tt.chirp, tt.inFeed); got != tt.want {
+				t.Errorf("CanSee() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/hugermuger/chirpy/internal/chirptext"
+	"github.com/hugermuger/chirpy/internal/database"
+	"github.com/hugermuger/chirpy/internal/linkpreview"
+)
+
+const (
+	// linkPreviewTTL is how long a fetched preview, or a failed fetch, is
+	// kept before the page is fetched again.
+	linkPreviewTTL   = 24 * time.Hour
+	linkPreviewQueue = 256
+	maxLinksPerChirp = 4
+)
+
+// Link is a URL in a chirp's body. Start and End are byte offsets into the
+// body. The preview fields are empty until the page has been fetched.
+type Link struct {
+	URL         string `json:"url"`
+	Start       int    `json:"start"`
+	End         int    `json:"end"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+// runPreviewWorkers starts the goroutines that fetch the pages queued by
+// queueLinkPreviews.
+func (cfg *apiConfig) runPreviewWorkers(n int) {
+	for range n {
+		go func() {
+			for url := range cfg.previewQueue {
+				cfg.fetchLinkPreview(context.Background(), url)
+			}
+		}()
+	}
+}
+
+// queueLinkPreviews hands the links in body to the preview workers. It never
+// blocks: when the queue is full the link goes without a preview.
+func (cfg *apiConfig) queueLinkPreviews(body string) {
+	if cfg.previewQueue == nil {
+		return
+	}
+	urls := chirptext.URLs(body)
+	if len(urls) > maxLinksPerChirp {
+		urls = urls[:maxLinksPerChirp]
+	}
+	for _, url := range urls {
+		select {
+		case cfg.previewQueue <- url.URL:
+		default:
+			log.Printf("Link preview queue is full, skipping %s", url.URL)
+		}
+	}
+}
+
+func (cfg *apiConfig) fetchLinkPreview(ctx context.Context, url string) {
+	fresh, err := cfg.dbQueries.HasFreshLinkPreview(ctx, database.HasFreshLinkPreviewParams{
+		Url:       url,
+		FetchedAt: time.Now().Add(-linkPreviewTTL),
+	})
+	if err != nil {
+		log.Printf("Couldn't check link preview for %s: %s", url, err)
+		return
+	}
+	if fresh {
+		return
+	}
+
+	// Failures are stored too, so a dead link isn't fetched for every chirp
+	// that mentions it.
+	params := database.UpsertLinkPreviewParams{Url: url}
+	preview, err := cfg.previews.Fetch(ctx, url)
+	if err != nil {
+		log.Printf("Couldn't fetch link preview for %s: %s", url, err)
+	} else {
+		params.Ok = true
+		params.Title = preview.Title
+		params.Description = preview.Description
+		params.ImageUrl = preview.ImageURL
+		params.SiteName = preview.SiteName
+	}
+
+	err = cfg.dbQueries.UpsertLinkPreview(ctx, params)
+	if err != nil {
+		log.Printf("Couldn't save link preview for %s: %s", url, err)
+	}
+}
+
+func (cfg *apiConfig) attachLinks(ctx context.Context, chirps []Chirp) error {
+	urls := []string{}
+	for i := range chirps {
+		for _, url := range chirptext.URLs(chirps[i].Body) {
+			chirps[i].Links = append(chirps[i].Links, Link{
+				URL:   url.URL,
+				Start: url.Start,
+				End:   url.End,
+			})
+			urls = append(urls, url.URL)
+		}
+	}
+	if len(urls) == 0 {
+		return nil
+	}
+
+	previews, err := cfg.dbQueries.GetLinkPreviews(ctx, urls)
+	if err != nil {
+		return err
+	}
+	byURL := map[string]database.LinkPreview{}
+	for _, preview := range previews {
+		byURL[preview.Url] = preview
+	}
+
+	for i := range chirps {
+		for j := range chirps[i].Links {
+			link := &chirps[i].Links[j]
+			preview, ok := byURL[link.URL]
+			if !ok {
+				continue
+			}
+			link.Title = preview.Title
+			link.Description = preview.Description
+			link.ImageURL = preview.ImageUrl
+			link.SiteName = preview.SiteName
+		}
+	}
+	return nil
+}
+
+func newLinkPreviewFetcher() *linkpreview.Fetcher {
+	opts := linkpreview.DefaultOptions()
+	opts.Timeout = envDuration("LINK_PREVIEW_TIMEOUT", opts.Timeout)
+	opts.MaxBytes = int64(envInt("LINK_PREVIEW_MAX_BYTES", int(opts.MaxBytes)))
+	return linkpreview.NewFetcher(opts)
+}
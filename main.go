@@ -1,23 +1,55 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
+	"net/netip"
 	"os"
+	"path/filepath"
+	"strconv"
 	"sync/atomic"
+	"time"
 
+	"github.com/hugermuger/chirpy/internal/auth"
 	"github.com/hugermuger/chirpy/internal/database"
+	"github.com/hugermuger/chirpy/internal/impressions"
+	"github.com/hugermuger/chirpy/internal/linkpreview"
+	"github.com/hugermuger/chirpy/internal/media"
+	"github.com/hugermuger/chirpy/internal/moderation"
+	"github.com/hugermuger/chirpy/internal/ratelimit"
+	"github.com/hugermuger/chirpy/internal/spam"
+	"github.com/hugermuger/chirpy/internal/stream"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	dbQueries      *database.Queries
-	platform       string
-	secret         string
-	polkaKey       string
+	fileserverHits  atomic.Int32
+	db              *sql.DB
+	dbQueries       *database.Queries
+	platform        string
+	secret          string
+	polkaKey        string
+	passwordPolicy  auth.PasswordPolicy
+	deletionGrace   time.Duration
+	trashRetention  time.Duration
+	exportDir       string
+	exportTTL       time.Duration
+	moderator       *moderation.Pipeline
+	chirpLimits     chirpLimits
+	duplicateWindow time.Duration
+	spam            spam.Config
+	rateLimits      map[string]ratelimit.Limit
+	rateStore       ratelimit.Store
+	trustedProxies  []netip.Prefix
+	blobs           media.BlobStore
+	previews        *linkpreview.Fetcher
+	previewQueue    chan string
+	views           *impressions.Counter
+	hub             *stream.Hub
+	broadcaster     stream.Broadcaster
 }
 
 func main() {
@@ -37,28 +69,154 @@ func main() {
 		log.Fatalf("Error opening database: %s", err)
 	}
 
+	cfg.db = db
 	cfg.dbQueries = database.New(db)
 	cfg.platform = os.Getenv("PLATFORM")
 	cfg.secret = os.Getenv("SECRET")
 	cfg.polkaKey = os.Getenv("POLKA_KEY")
 
+	cfg.passwordPolicy = auth.DefaultPasswordPolicy()
+	cfg.passwordPolicy.MinLength = envInt("PASSWORD_MIN_LENGTH", cfg.passwordPolicy.MinLength)
+	cfg.passwordPolicy.MaxLength = envInt("PASSWORD_MAX_LENGTH", cfg.passwordPolicy.MaxLength)
+	cfg.passwordPolicy.DisallowEmail = os.Getenv("PASSWORD_ALLOW_EMAIL") != "true"
+	cfg.deletionGrace = envDuration("ACCOUNT_DELETION_GRACE", 30*24*time.Hour)
+	cfg.trashRetention = envDuration("CHIRP_TRASH_RETENTION", 30*24*time.Hour)
+
+	cfg.exportDir = os.Getenv("EXPORT_DIR")
+	if cfg.exportDir == "" {
+		cfg.exportDir = filepath.Join(os.TempDir(), "chirpy-exports")
+	}
+	cfg.exportTTL = envDuration("EXPORT_LINK_TTL", 24*time.Hour)
+
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = filepath.Join(os.TempDir(), "chirpy-media")
+	}
+	cfg.blobs, err = media.NewFileStore(mediaDir)
+	if err != nil {
+		log.Fatalf("Error opening media directory: %s", err)
+	}
+
+	cfg.previews = newLinkPreviewFetcher()
+	cfg.views = impressions.NewCounter(viewBucket)
+	cfg.hub = stream.NewHub(streamHistory, streamBuffer)
+	cfg.broadcaster = stream.Local{Hub: cfg.hub}
+
+	cfg.chirpLimits = chirpLimits{
+		Default:   envInt("CHIRP_MAX_LENGTH", 140),
+		ChirpyRed: envInt("CHIRP_MAX_LENGTH_RED", 280),
+	}
+
+	cfg.duplicateWindow = envDuration("CHIRP_DUPLICATE_WINDOW", 24*time.Hour)
+
+	cfg.spam = spam.DefaultConfig()
+	if path := os.Getenv("SPAM_CONFIG"); path != "" {
+		cfg.spam, err = spam.LoadConfig(path)
+		if err != nil {
+			log.Fatalf("Error loading spam config: %s", err)
+		}
+	}
+
+	cfg.rateLimits = loadRateLimits()
+	cfg.rateStore = ratelimit.NewMemoryStore()
+	cfg.trustedProxies, err = ratelimit.ParseProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("Error parsing TRUSTED_PROXIES: %s", err)
+	}
+
+	configureHashParams()
+
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		breached, err := auth.LoadBreachedPasswords(path)
+		if err != nil {
+			log.Fatalf("Error loading breached passwords: %s", err)
+		}
+		cfg.passwordPolicy.Breached = breached
+	}
+
+	err = cfg.loadModerator(context.Background(), os.Getenv("MODERATION_CONFIG"))
+	if err != nil {
+		log.Fatalf("Error loading moderation rules: %s", err)
+	}
+
+	if runCommand(&cfg, os.Args[1:]) {
+		return
+	}
+
 	mux := http.NewServeMux()
 
 	mux.Handle("/app/", cfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot)))))
 	mux.HandleFunc("GET /api/healthz", readinessEndpoint)
 	mux.HandleFunc("GET /admin/metrics", cfg.metricsRead)
 	mux.HandleFunc("POST /admin/reset", cfg.metricsReset)
-	mux.HandleFunc("POST /api/chirps", cfg.addChirp)
+	mux.HandleFunc("POST /admin/users/{userID}/suspension", cfg.suspendUserHandler)
+	mux.HandleFunc("DELETE /admin/users/{userID}/suspension", cfg.unsuspendUserHandler)
+	mux.HandleFunc("POST /admin/users/{userID}/shadow-ban", cfg.shadowBanHandler)
+	mux.HandleFunc("DELETE /admin/users/{userID}/shadow-ban", cfg.liftShadowBanHandler)
+	mux.HandleFunc("GET /admin/users/{userID}/sanctions", cfg.getUserSanctions)
+	mux.Handle("POST /api/chirps", cfg.rateLimit("chirps", cfg.addChirp))
+	mux.Handle("POST /api/chirps/import", cfg.rateLimit("chirps", cfg.importChirpsHandler))
 	mux.HandleFunc("GET /api/chirps", cfg.getChirps)
+	mux.HandleFunc("GET /api/drafts", cfg.listDrafts)
+	mux.HandleFunc("PUT /api/drafts/{chirpID}", cfg.updateDraft)
+	mux.HandleFunc("DELETE /api/drafts/{chirpID}", cfg.cancelDraft)
+	mux.HandleFunc("GET /api/stream", cfg.streamChirps)
+	mux.HandleFunc("GET /api/ws", cfg.serveWebSocket)
+	mux.HandleFunc("GET /api/chirps/trash", cfg.listTrash)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", cfg.getChirp)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.deleteChirp)
-	mux.HandleFunc("POST /api/users", cfg.addUser)
-	mux.HandleFunc("POST /api/login", cfg.loginUser)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/restore", cfg.restoreChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/poll/votes", cfg.votePoll)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/bookmark", cfg.bookmarkChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/bookmark", cfg.unbookmarkChirp)
+	mux.Handle("POST /api/chirps/{chirpID}/reports", cfg.rateLimit("reports", cfg.reportChirp))
+	mux.HandleFunc("POST /api/lists", cfg.createList)
+	mux.HandleFunc("GET /api/lists/{listID}", cfg.getList)
+	mux.HandleFunc("PUT /api/lists/{listID}", cfg.updateList)
+	mux.HandleFunc("DELETE /api/lists/{listID}", cfg.deleteList)
+	mux.HandleFunc("GET /api/lists/{listID}/chirps", cfg.getListChirps)
+	mux.HandleFunc("GET /api/lists/{listID}/members", cfg.getListMembers)
+	mux.HandleFunc("PUT /api/lists/{listID}/members/{userID}", cfg.addListMember)
+	mux.HandleFunc("DELETE /api/lists/{listID}/members/{userID}", cfg.removeListMember)
+	mux.HandleFunc("GET /api/moderation/reports", cfg.listReports)
+	mux.HandleFunc("POST /api/moderation/reports/{reportID}/actions", cfg.actOnReport)
+	mux.HandleFunc("GET /api/moderation/actions", cfg.listModerationActions)
+	mux.Handle("POST /api/media", cfg.rateLimit("media", cfg.uploadMedia))
+	mux.HandleFunc("GET /api/media/{mediaID}", cfg.getMedia)
+	mux.HandleFunc("GET /api/media/{mediaID}/thumbnail", cfg.getMediaThumbnail)
+	mux.Handle("POST /api/users", cfg.rateLimit("signup", cfg.addUser))
+	mux.Handle("POST /api/login", cfg.rateLimit("login", cfg.loginUser))
 	mux.HandleFunc("PUT /api/users", cfg.updateUser)
+	mux.HandleFunc("DELETE /api/users/me", cfg.deleteUser)
+	mux.Handle("PUT /api/users/me/avatar", cfg.rateLimit("media", cfg.putAvatar))
+	mux.HandleFunc("DELETE /api/users/me/avatar", cfg.deleteAvatar)
+	mux.HandleFunc("GET /api/users/me/analytics", cfg.getAnalytics)
+	mux.HandleFunc("GET /api/users/me/bookmarks", cfg.listBookmarks)
+	mux.HandleFunc("GET /api/users/me/bookmarks/collections", cfg.listBookmarkCollections)
+	mux.HandleFunc("POST /api/users/me/bookmarks/collections", cfg.createBookmarkCollection)
+	mux.HandleFunc("PUT /api/users/me/bookmarks/collections/{collectionID}", cfg.renameBookmarkCollection)
+	mux.HandleFunc("DELETE /api/users/me/bookmarks/collections/{collectionID}", cfg.deleteBookmarkCollection)
+	mux.HandleFunc("GET /api/users/{userID}/avatar/{hash}/{size}", cfg.getAvatar)
+	mux.HandleFunc("POST /api/users/me/export", cfg.requestExport)
+	mux.HandleFunc("GET /api/users/me/export/{exportID}", cfg.getExport)
+	mux.HandleFunc("GET /api/exports/{exportID}/download", cfg.downloadExport)
+	mux.HandleFunc("POST /api/users/{userID}/block", cfg.blockUser)
+	mux.HandleFunc("DELETE /api/users/{userID}/block", cfg.unblockUser)
+	mux.HandleFunc("POST /api/users/{userID}/mute", cfg.muteUser)
+	mux.HandleFunc("DELETE /api/users/{userID}/mute", cfg.unmuteUser)
+	mux.HandleFunc("POST /api/users/{userID}/follow", cfg.followUser)
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", cfg.unfollowUser)
+	mux.HandleFunc("GET /api/users/{userID}/lists", cfg.getUserLists)
 	mux.HandleFunc("POST /api/refresh", cfg.refreshToken)
 	mux.HandleFunc("POST /api/revoke", cfg.revokeToken)
 	mux.HandleFunc("POST /api/polka/webhooks", cfg.setUserRed)
 
+	go cfg.runPurgeJob(time.Hour)
+	cfg.previewQueue = make(chan string, linkPreviewQueue)
+	cfg.runPreviewWorkers(envInt("LINK_PREVIEW_WORKERS", 2))
+	go cfg.runScheduler(30 * time.Second)
+	go cfg.runViewFlusher(envDuration("VIEW_FLUSH_INTERVAL", time.Minute))
+
 	server := http.Server{
 		Addr:    ":" + port,
 		Handler: mux,
@@ -67,3 +225,56 @@ func main() {
 	log.Printf("Serving from %v on port: %v\n", filepathRoot, port)
 	log.Fatal(server.ListenAndServe())
 }
+
+// configureHashParams sets the argon2id parameters from the environment. With
+// ARGON2_TARGET set (e.g. "250ms") the parameters are benchmarked on startup
+// instead, using ARGON2_MEMORY_KIB as the upper bound for memory. Stored
+// hashes are only upgraded towards the configured parameters, never towards
+// the tuned ones.
+func configureHashParams() {
+	params := *auth.HashParams()
+	params.Memory = uint32(envInt("ARGON2_MEMORY_KIB", int(params.Memory)))
+	params.Iterations = uint32(envInt("ARGON2_ITERATIONS", int(params.Iterations)))
+	params.Parallelism = uint8(envInt("ARGON2_PARALLELISM", int(params.Parallelism)))
+	target := params
+	auth.SetRehashTarget(&target)
+
+	if target := os.Getenv("ARGON2_TARGET"); target != "" {
+		duration, err := time.ParseDuration(target)
+		if err != nil {
+			log.Fatalf("ARGON2_TARGET must be a duration: %s", err)
+		}
+		tuned, err := auth.TuneHashParams(duration, params.Memory, params.Parallelism)
+		if err != nil {
+			log.Fatalf("Error tuning argon2id parameters: %s", err)
+		}
+		params = *tuned
+		log.Printf("Tuned argon2id parameters: m=%d t=%d p=%d", params.Memory, params.Iterations, params.Parallelism)
+	}
+
+	auth.SetHashParams(&params)
+}
+
+func envInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("%s must be an integer: %s", key, err)
+	}
+	return n
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("%s must be a duration: %s", key, err)
+	}
+	return d
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/database"
+)
+
+const (
+	maxMediaPerChirp = 4
+	maxAltTextLength = 1000
+	maxUploadSize    = 10 << 20
+	// orphanedMediaTTL is how long an upload may stay unattached before
+	// the purge job removes it. Media of deleted chirps is unattached too.
+	orphanedMediaTTL = 24 * time.Hour
+)
+
+type Media struct {
+	ID           uuid.UUID `json:"id"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	ContentType  string    `json:"content_type"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+	AltText      string    `json:"alt_text"`
+}
+
+type setMedia struct {
+	ID      uuid.UUID `json:"id"`
+	AltText string    `json:"alt_text"`
+}
+
+func blobKey(id uuid.UUID) string {
+	return id.String()
+}
+
+func thumbnailKey(id uuid.UUID) string {
+	return id.String() + "_thumb"
+}
+
+func jsonMedia(attachment database.Attachment) Media {
+	return Media{
+		ID:           attachment.ID,
+		URL:          "/api/media/" + attachment.ID.String(),
+		ThumbnailURL: "/api/media/" + attachment.ID.String() + "/thumbnail",
+		ContentType:  attachment.ContentType,
+		Width:        attachment.Width,
+		Height:       attachment.Height,
+		AltText:      attachment.AltText,
+	}
+}
+
+func (cfg *apiConfig) attachMedia(ctx context.Context, chirps []Chirp) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+	ids := []uuid.UUID{}
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+	}
+
+	attachments, err := cfg.dbQueries.GetAttachmentsForChirps(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	byChirp := map[uuid.UUID][]Media{}
+	for _, attachment := range attachments {
+		byChirp[attachment.ChirpID.UUID] = append(byChirp[attachment.ChirpID.UUID], jsonMedia(attachment))
+	}
+	for i := range chirps {
+		chirps[i].Media = byChirp[chirps[i].ID]
+	}
+	return nil
+}
+
+// expandChirps adds what a chirp's JSON carries beyond its own row.
+func (cfg *apiConfig) expandChirps(ctx context.Context, viewerID uuid.NullUUID, chirps []Chirp) error {
+	err := cfg.attachPolls(ctx, viewerID, chirps)
+	if err != nil {
+		return err
+	}
+	err = cfg.attachMedia(ctx, chirps)
+	if err != nil {
+		return err
+	}
+	return cfg.attachLinks(ctx, chirps)
+}
+
+func (cfg *apiConfig) purgeOrphanedMedia(ctx context.Context) {
+	attachments, err := cfg.dbQueries.GetOrphanedAttachments(ctx, time.Now().Add(-orphanedMediaTTL))
+	if err != nil {
+		log.Printf("Couldn't get orphaned media: %s", err)
+		return
+	}
+
+	for _, attachment := range attachments {
+		err = cfg.blobs.Delete(ctx, blobKey(attachment.ID))
+		if err == nil {
+			err = cfg.blobs.Delete(ctx, thumbnailKey(attachment.ID))
+		}
+		if err != nil {
+			log.Printf("Couldn't remove media %s: %s", attachment.ID, err)
+			continue
+		}
+		err = cfg.dbQueries.DeleteAttachment(ctx, attachment.ID)
+		if err != nil {
+			log.Printf("Couldn't delete media %s: %s", attachment.ID, err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/database"
+	"github.com/hugermuger/chirpy/internal/moderation"
+)
+
+// loadModerator builds the moderation pipeline from the config file (or the
+// built-in word list) followed by the terms stored in moderation_terms.
+func (cfg *apiConfig) loadModerator(ctx context.Context, configPath string) error {
+	config := moderation.DefaultConfig()
+	if configPath != "" {
+		var err error
+		config, err = moderation.LoadConfig(configPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	filters, err := config.Filters()
+	if err != nil {
+		return err
+	}
+
+	terms, err := cfg.dbQueries.GetModerationTerms(ctx)
+	if err != nil {
+		return fmt.Errorf("couldn't load moderation terms: %w", err)
+	}
+
+	byAction := map[moderation.Action][]string{}
+	for _, term := range terms {
+		action, err := moderation.ParseAction(term.Action)
+		if err != nil {
+			return fmt.Errorf("moderation term %q: %w", term.Term, err)
+		}
+		byAction[action] = append(byAction[action], term.Term)
+	}
+	for _, action := range []moderation.Action{moderation.Mask, moderation.Flag, moderation.Reject} {
+		if words := byAction[action]; len(words) > 0 {
+			filters = append(filters, moderation.NewWordFilter("terms-"+action.String(), action, words))
+		}
+	}
+
+	cfg.moderator = moderation.New(filters...)
+	return nil
+}
+
+// recordFlags stores the rules that flagged a chirp and puts the chirp in the
+// moderation queue. A failure here shouldn't fail the chirp, so errors are
+// only logged.
+func (cfg *apiConfig) recordFlags(ctx context.Context, chirpID uuid.UUID, result moderation.Result) {
+	details := []string{}
+	for _, match := range result.Matches {
+		if match.Action != moderation.Flag {
+			continue
+		}
+		details = append(details, match.Rule+": "+match.Term)
+		err := cfg.dbQueries.CreateChirpFlag(ctx, database.CreateChirpFlagParams{
+			ChirpID: chirpID,
+			Rule:    match.Rule,
+			Term:    match.Term,
+		})
+		if err != nil {
+			log.Printf("Couldn't flag chirp %s: %s", chirpID, err)
+		}
+	}
+	if len(details) == 0 {
+		return
+	}
+
+	_, err := cfg.dbQueries.CreateReport(ctx, database.CreateReportParams{
+		ChirpID: uuid.NullUUID{UUID: chirpID, Valid: true},
+		Reason:  reasonAutoFlag,
+		Details: strings.Join(details, "\n"),
+	})
+	if err != nil {
+		log.Printf("Couldn't queue flagged chirp %s: %s", chirpID, err)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// pageQuery is where a listing continues. Listings are newest first, so the next
+// page starts before the last item of the previous one. Items can share a
+// timestamp, so they are ordered by ID after it and the cursor holds both.
+type pageQuery struct {
+	Before   sql.NullTime
+	BeforeID uuid.UUID
+	Size     int32
+}
+
+// parsePage reads the limit, before and before_id query parameters. before is
+// the timestamp of the last item already seen, in RFC 3339 format, and
+// before_id its ID. Without before_id, the page starts strictly before the
+// timestamp.
+func parsePage(r *http.Request) (pageQuery, error) {
+	p := pageQuery{Size: defaultPageSize}
+
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxPageSize {
+			return pageQuery{}, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		p.Size = int32(n)
+	}
+
+	if s := r.URL.Query().Get("before"); s != "" {
+		before, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return pageQuery{}, errors.New("before must be an RFC 3339 timestamp")
+		}
+		p.Before = sql.NullTime{Time: before, Valid: true}
+	}
+
+	if s := r.URL.Query().Get("before_id"); s != "" {
+		if !p.Before.Valid {
+			return pageQuery{}, errors.New("before_id needs before")
+		}
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return pageQuery{}, errors.New("before_id must be a UUID")
+		}
+		p.BeforeID = id
+	}
+	return p, nil
+}
+
+// setNextPage points the client at the page after one that ended with the
+// item lastID from last, in a Link header.
+func setNextPage(w http.ResponseWriter, r *http.Request, last time.Time, lastID uuid.UUID) {
+	query := r.URL.Query()
+	query.Set("before", last.Format(time.RFC3339Nano))
+	query.Set("before_id", lastID.String())
+	next := *r.URL
+	next.RawQuery = query.Encode()
+	w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+}
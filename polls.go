@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/chirptext"
+	"github.com/hugermuger/chirpy/internal/database"
+	"github.com/hugermuger/chirpy/internal/moderation"
+)
+
+const (
+	minPollOptions      = 2
+	maxPollOptions      = 4
+	maxPollOptionLength = 25
+	minPollDuration     = 5 * time.Minute
+	maxPollDuration     = 7 * 24 * time.Hour
+	defaultPollDuration = 24 * time.Hour
+)
+
+// Poll is included in a chirp's JSON. Votes and TotalVotes stay empty until
+// the viewer has voted or the poll has closed, so the tally can't sway votes.
+type Poll struct {
+	ClosesAt    time.Time    `json:"closes_at"`
+	Closed      bool         `json:"closed"`
+	Options     []PollOption `json:"options"`
+	TotalVotes  *int64       `json:"total_votes,omitempty"`
+	VotedOption *uuid.UUID   `json:"voted_option,omitempty"`
+}
+
+type PollOption struct {
+	ID    uuid.UUID `json:"id"`
+	Label string    `json:"label"`
+	Votes *int64    `json:"votes,omitempty"`
+}
+
+type setPoll struct {
+	Options  []string `json:"options"`
+	Duration string   `json:"duration"`
+}
+
+var errInvalidPoll = errors.New("Invalid poll")
+
+// validate checks the options and returns how long the poll stays open.
+// Options go through the moderation pipeline like the chirp itself and are
+// replaced by their masked text.
+func (p *setPoll) validate(moderator *moderation.Pipeline) (time.Duration, error) {
+	if len(p.Options) < minPollOptions || len(p.Options) > maxPollOptions {
+		return 0, fmt.Errorf("%w: a poll needs %d to %d options", errInvalidPoll, minPollOptions, maxPollOptions)
+	}
+
+	seen := map[string]bool{}
+	for i, option := range p.Options {
+		option = strings.TrimSpace(option)
+		if option == "" || chirptext.Length(option) > maxPollOptionLength {
+			return 0, fmt.Errorf("%w: options must be 1 to %d characters", errInvalidPoll, maxPollOptionLength)
+		}
+		if seen[strings.ToLower(option)] {
+			return 0, fmt.Errorf("%w: options must be different", errInvalidPoll)
+		}
+		seen[strings.ToLower(option)] = true
+
+		result := moderator.Moderate(option)
+		if result.Action == moderation.Reject {
+			return 0, errChirpRejected
+		}
+		p.Options[i] = result.Text
+	}
+
+	if p.Duration == "" {
+		return defaultPollDuration, nil
+	}
+	duration, err := time.ParseDuration(p.Duration)
+	if err != nil || duration < minPollDuration || duration > maxPollDuration {
+		return 0, fmt.Errorf("%w: duration must be between %s and %s", errInvalidPoll, minPollDuration, maxPollDuration)
+	}
+	return duration, nil
+}
+
+func createPoll(ctx context.Context, q *database.Queries, chirpID uuid.UUID, poll setPoll, duration time.Duration) error {
+	_, err := q.CreatePoll(ctx, database.CreatePollParams{
+		ChirpID:  chirpID,
+		ClosesAt: time.Now().Add(duration),
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, label := range poll.Options {
+		_, err := q.CreatePollOption(ctx, database.CreatePollOptionParams{
+			ChirpID:  chirpID,
+			Position: int32(i),
+			Label:    label,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachPolls fills in the polls of the given chirps as the viewer gets to
+// see them. It takes three queries no matter how many chirps there are.
+func (cfg *apiConfig) attachPolls(ctx context.Context, viewerID uuid.NullUUID, chirps []Chirp) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+	ids := []uuid.UUID{}
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+	}
+
+	polls, err := cfg.dbQueries.GetPolls(ctx, ids)
+	if err != nil || len(polls) == 0 {
+		return err
+	}
+
+	options, err := cfg.dbQueries.GetPollOptions(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	voted := map[uuid.UUID]uuid.UUID{}
+	if viewerID.Valid {
+		votes, err := cfg.dbQueries.GetPollVotesByUser(ctx, database.GetPollVotesByUserParams{
+			UserID:   viewerID.UUID,
+			ChirpIds: ids,
+		})
+		if err != nil {
+			return err
+		}
+		for _, vote := range votes {
+			voted[vote.ChirpID] = vote.OptionID
+		}
+	}
+
+	byChirp := map[uuid.UUID]*Poll{}
+	for _, poll := range polls {
+		byChirp[poll.ChirpID] = &Poll{
+			ClosesAt: poll.ClosesAt,
+			Closed:   !poll.ClosesAt.After(time.Now()),
+			Options:  []PollOption{},
+		}
+		if option, ok := voted[poll.ChirpID]; ok {
+			byChirp[poll.ChirpID].VotedOption = &option
+		}
+	}
+
+	for _, option := range options {
+		poll, ok := byChirp[option.ChirpID]
+		if !ok {
+			continue
+		}
+		jsonOption := PollOption{ID: option.ID, Label: option.Label}
+		if poll.Closed || poll.VotedOption != nil {
+			votes := option.Votes
+			jsonOption.Votes = &votes
+			if poll.TotalVotes == nil {
+				poll.TotalVotes = new(int64)
+			}
+			*poll.TotalVotes += votes
+		}
+		poll.Options = append(poll.Options, jsonOption)
+	}
+
+	for i := range chirps {
+		chirps[i].Poll = byChirp[chirps[i].ID]
+	}
+	return nil
+}
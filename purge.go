@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/database"
+)
+
+// runPurgeJob periodically removes what has outlived its use: accounts
+// past their deletion grace period, chirps left in the trash too long,
+// exports that are expired or were lost mid-build, and uploads never
+// attached to a chirp. It also forgets idle rate limit buckets.
+func (cfg *apiConfig) runPurgeJob(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cfg.purgeDeletedUsers(context.Background())
+		cfg.purgeDeletedChirps(context.Background())
+		cfg.purgeExpiredExports(context.Background())
+		cfg.failStaleExports(context.Background())
+		cfg.purgeOrphanedMedia(context.Background())
+		cfg.sweepRateLimits()
+		<-ticker.C
+	}
+}
+
+// purgeDeletedUsers hard-deletes accounts whose grace period has run out.
+// Their chirps and refresh tokens go with them through ON DELETE CASCADE,
+// and so do their export rows, so the export files are removed first. A
+// user whose file can't be removed is kept for the next run to try again.
+func (cfg *apiConfig) purgeDeletedUsers(ctx context.Context) {
+	cutoff := time.Now().Add(-cfg.deletionGrace)
+
+	exports, err := cfg.dbQueries.GetDataExportsOfDeletedUsers(ctx, cutoff)
+	if err != nil {
+		log.Printf("Couldn't get exports of deleted users: %s", err)
+		return
+	}
+	keep := []uuid.UUID{}
+	for _, export := range exports {
+		if !removeExportFile(export) {
+			keep = append(keep, export.UserID)
+		}
+	}
+
+	purged, err := cfg.dbQueries.PurgeDeletedUsers(ctx, database.PurgeDeletedUsersParams{
+		DeletedAt: cutoff,
+		KeepIds:   keep,
+	})
+	if err != nil {
+		log.Printf("Couldn't purge deleted users: %s", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("Purged %d deleted users", purged)
+	}
+}
+
+// purgeDeletedChirps hard-deletes chirps that have been in the trash too
+// long. Their bookmarks go with them through ON DELETE CASCADE.
+func (cfg *apiConfig) purgeDeletedChirps(ctx context.Context) {
+	purged, err := cfg.dbQueries.PurgeDeletedChirps(ctx, cfg.trashCutoff())
+	if err != nil {
+		log.Printf("Couldn't purge deleted chirps: %s", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("Purged %d deleted chirps", purged)
+	}
+}
+
+// purgeExpiredExports removes exports whose download link has expired.
+func (cfg *apiConfig) purgeExpiredExports(ctx context.Context) {
+	exports, err := cfg.dbQueries.GetExpiredDataExports(ctx, sql.NullTime{Time: time.Now(), Valid: true})
+	if err != nil {
+		log.Printf("Couldn't get expired exports: %s", err)
+		return
+	}
+
+	for _, export := range exports {
+		if !removeExportFile(export) {
+			continue
+		}
+		err = cfg.dbQueries.DeleteDataExport(ctx, export.ID)
+		if err != nil {
+			log.Printf("Couldn't delete export %s: %s", export.ID, err)
+		}
+	}
+}
+
+// failStaleExports marks exports that have been pending for longer than
+// exportTimeout as failed. Their build was lost when a server stopped, and
+// the user can ask for a new one.
+func (cfg *apiConfig) failStaleExports(ctx context.Context) {
+	failed, err := cfg.dbQueries.FailStaleDataExports(ctx, time.Now().Add(-exportTimeout))
+	if err != nil {
+		log.Printf("Couldn't fail stale exports: %s", err)
+		return
+	}
+	if failed > 0 {
+		log.Printf("Marked %d stale exports as failed", failed)
+	}
+}
+
+// removeExportFile deletes the export's file, if it has one. It reports
+// whether the file is gone.
+func removeExportFile(export database.DataExport) bool {
+	if !export.FilePath.Valid {
+		return true
+	}
+	err := os.Remove(export.FilePath.String)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("Couldn't remove export %s: %s", export.ID, err)
+		return false
+	}
+	return true
+}
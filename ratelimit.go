@@ -0,0 +1,105 @@
+package main
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hugermuger/chirpy/internal/auth"
+	"github.com/hugermuger/chirpy/internal/ratelimit"
+)
+
+// defaultRateLimits are the route groups that are rate limited. Each can be
+// changed with RATE_LIMIT_<GROUP> (e.g. RATE_LIMIT_LOGIN=10/1m) or turned
+// off with "off".
+var defaultRateLimits = map[string]string{
+	"login":   "5/1m",
+	"signup":  "5/1h",
+	"chirps":  "30/1m",
+	"reports": "10/1m",
+	"media":   "30/1h",
+}
+
+func loadRateLimits() map[string]ratelimit.Limit {
+	limits := map[string]ratelimit.Limit{}
+	for group, fallback := range defaultRateLimits {
+		key := "RATE_LIMIT_" + strings.ToUpper(group)
+		value := os.Getenv(key)
+		if value == "" {
+			value = fallback
+		}
+		if value == "off" {
+			continue
+		}
+		limit, err := ratelimit.ParseLimit(value)
+		if err != nil {
+			log.Fatalf("%s: %s", key, err)
+		}
+		limits[group] = limit
+	}
+	return limits
+}
+
+// rateLimit wraps a handler in the limit of its route group. Requests with a
+// valid access token are counted per user, everything else per client IP.
+// If the store fails the request is let through.
+func (cfg *apiConfig) rateLimit(group string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit, ok := cfg.rateLimits[group]
+		if !ok {
+			next(w, r)
+			return
+		}
+
+		result, err := cfg.rateStore.Take(r.Context(), group+":"+cfg.rateLimitKey(r), limit)
+		if err != nil {
+			log.Printf("Couldn't check rate limit for %s: %s", group, err)
+			next(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		if !result.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			respondWithError(w, http.StatusTooManyRequests, "Too many requests", nil)
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+func (cfg *apiConfig) rateLimitKey(r *http.Request) string {
+	token, err := auth.GetBearerToken(r.Header)
+	if err == nil {
+		userID, err := auth.ValidateJWT(token, cfg.secret)
+		if err == nil {
+			return "user:" + userID.String()
+		}
+	}
+	return "ip:" + ratelimit.ClientIP(r, cfg.trustedProxies)
+}
+
+// sweepRateLimits forgets buckets of the in-memory store that have been idle
+// long enough to be full again.
+func (cfg *apiConfig) sweepRateLimits() {
+	store, ok := cfg.rateStore.(*ratelimit.MemoryStore)
+	if !ok {
+		return
+	}
+	idle := time.Duration(0)
+	for _, limit := range cfg.rateLimits {
+		idle = max(idle, limit.Per)
+	}
+	store.Sweep(idle)
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/hugermuger/chirpy/internal/database"
+	"github.com/hugermuger/chirpy/internal/spam"
+)
+
+// runScheduler publishes scheduled chirps once they are due. The schedule
+// lives in the database, so chirps that came due while the server was down go
+// out on the first run, and ClaimDueChirp locks the row it claims so several
+// instances never publish the same chirp twice.
+func (cfg *apiConfig) runScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cfg.publishDueChirps(context.Background())
+		<-ticker.C
+	}
+}
+
+func (cfg *apiConfig) publishDueChirps(ctx context.Context) {
+	handled := 0
+	for {
+		ok, err := cfg.publishNextDueChirp(ctx)
+		if err != nil {
+			log.Printf("Couldn't publish scheduled chirps: %s", err)
+			break
+		}
+		if !ok {
+			break
+		}
+		handled++
+	}
+	if handled > 0 {
+		log.Printf("Handled %d scheduled chirps", handled)
+	}
+}
+
+// publishNextDueChirp claims the next due chirp and puts it through the same
+// duplicate and spam checks as addChirp. Chirps are claimed one at a time so
+// each is scored against the ones published before it. A duplicate, or a
+// chirp that waiting won't get past the scorer, goes back to the author's
+// drafts; a rate-limited one is pushed back until the limit is lifted. It
+// reports false when no chirp is due.
+func (cfg *apiConfig) publishNextDueChirp(ctx context.Context) (bool, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	q := cfg.dbQueries.WithTx(tx)
+
+	chirp, err := q.ClaimDueChirp(ctx, sql.NullTime{Time: time.Now(), Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	author, err := cfg.dbQueries.GetUserByID(ctx, chirp.UserID)
+	if err != nil {
+		return false, err
+	}
+
+	duplicate, err := cfg.isDuplicateChirp(ctx, q, chirp.UserID, chirp.Body)
+	if err != nil {
+		return false, err
+	}
+	decision, err := cfg.scoreChirp(ctx, author, chirp.Body)
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case duplicate || decision.Verdict == spam.Limit && decision.RetryAfter == 0:
+		log.Printf("Moved scheduled chirp %s back to drafts (duplicate: %t, verdict: %s)", chirp.ID, duplicate, decision.Verdict)
+		err = q.RescheduleChirp(ctx, database.RescheduleChirpParams{Status: "draft", ID: chirp.ID})
+		if err != nil {
+			return false, err
+		}
+		return true, tx.Commit()
+	case decision.Verdict == spam.Limit:
+		err = q.RescheduleChirp(ctx, database.RescheduleChirpParams{
+			Status:      "scheduled",
+			ScheduledAt: sql.NullTime{Time: time.Now().Add(decision.RetryAfter), Valid: true},
+			ID:          chirp.ID,
+		})
+		if err != nil {
+			return false, err
+		}
+		return true, tx.Commit()
+	}
+
+	status := "published"
+	if decision.Verdict == spam.Queue {
+		status = "pending"
+	}
+	chirp, err = q.PublishScheduledChirp(ctx, database.PublishScheduledChirpParams{Status: status, ID: chirp.ID})
+	if err != nil {
+		return false, err
+	}
+	if decision.Verdict == spam.Queue {
+		err = holdForReview(ctx, q, chirp.ID, decision)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	// The moderation rules may have changed since the chirp was written, so
+	// it's checked again for flags. It was masked when it was saved.
+	cfg.recordFlags(ctx, chirp.ID, cfg.moderator.Moderate(chirp.Body))
+	if chirp.Status == "published" {
+		cfg.queueLinkPreviews(chirp.Body)
+		cfg.publishChirp(ctx, chirp, author.ShadowBanned)
+	}
+	return true, nil
+}
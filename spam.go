@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/database"
+	"github.com/hugermuger/chirpy/internal/spam"
+)
+
+// scoreChirp runs the spam heuristics for a chirp the author is about to
+// post. Every decision is logged so the thresholds can be tuned against real
+// traffic.
+func (cfg *apiConfig) scoreChirp(ctx context.Context, author database.User, body string) (spam.Decision, error) {
+	now := time.Now()
+	lookback := max(time.Duration(cfg.spam.RateWindow), time.Duration(cfg.spam.SimilarityWindow))
+
+	rows, err := cfg.dbQueries.GetRecentChirpsByUser(ctx, database.GetRecentChirpsByUserParams{
+		UserID:    author.ID,
+		CreatedAt: now.Add(-lookback),
+	})
+	if err != nil {
+		return spam.Decision{}, err
+	}
+
+	recent := []spam.Post{}
+	for _, row := range rows {
+		recent = append(recent, spam.Post{Body: row.Body, CreatedAt: row.CreatedAt})
+	}
+
+	decision := cfg.spam.Score(spam.Input{
+		Body:       body,
+		AccountAge: now.Sub(author.CreatedAt),
+		Recent:     recent,
+		Now:        now,
+	})
+	log.Printf("spam: user=%s score=%.2f verdict=%s reasons=%q", author.ID, decision.Score, decision.Verdict, decision.Reasons)
+	return decision, nil
+}
+
+// holdForReview puts a chirp the scorer was unsure about in the moderation
+// queue. The chirp is stored as pending and only published once a moderator
+// dismisses the report, so q should be the transaction that creates it.
+func holdForReview(ctx context.Context, q *database.Queries, chirpID uuid.UUID, decision spam.Decision) error {
+	_, err := q.CreateReport(ctx, database.CreateReportParams{
+		ChirpID: uuid.NullUUID{UUID: chirpID, Valid: true},
+		Reason:  reasonAutoFlag,
+		Details: fmt.Sprintf("spam score %.1f: %s", decision.Score, strings.Join(decision.Reasons, "; ")),
+	})
+	return err
+}
+
+// retryAfterSeconds is the Retry-After value for a limited chirp. When waiting
+// alone won't get the chirp through, the client is told to wait out the
+// longest window the scorer looks at.
+func (cfg *apiConfig) retryAfterSeconds(decision spam.Decision) string {
+	wait := decision.RetryAfter
+	if wait <= 0 {
+		wait = max(time.Duration(cfg.spam.RateWindow), time.Duration(cfg.spam.SimilarityWindow))
+	}
+	return strconv.Itoa(int(math.Ceil(wait.Seconds())))
+}
@@ -0,0 +1,35 @@
+-- name: CreateAttachment :one
+INSERT INTO attachments (id, created_at, user_id, content_type, width, height, size)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING *;
+
+-- name: GetAttachment :one
+SELECT * FROM attachments WHERE id = $1;
+
+-- name: AttachToChirp :execrows
+UPDATE attachments
+SET
+    chirp_id = $1,
+    alt_text = $2,
+    position = $3
+WHERE id = $4 AND user_id = $5 AND chirp_id IS NULL;
+
+-- name: GetAttachmentsForChirps :many
+SELECT * FROM attachments
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_id, position;
+
+-- name: GetOrphanedAttachments :many
+SELECT * FROM attachments
+WHERE chirp_id IS NULL AND created_at < $1;
+
+-- name: DeleteAttachment :exec
+DELETE FROM attachments WHERE id = $1;
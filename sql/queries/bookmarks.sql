@@ -0,0 +1,54 @@
+-- name: UpsertBookmark :exec
+INSERT INTO bookmarks (user_id, chirp_id, collection_id, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (user_id, chirp_id) DO UPDATE
+SET collection_id = EXCLUDED.collection_id;
+
+-- name: DeleteBookmark :exec
+DELETE FROM bookmarks WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetBookmarks :many
+SELECT sqlc.embed(chirps), bookmarks.created_at AS bookmarked_at, bookmarks.collection_id FROM bookmarks
+JOIN chirps ON chirps.id = bookmarks.chirp_id
+JOIN users ON users.id = chirps.user_id
+WHERE bookmarks.user_id = sqlc.arg(user_id)
+    AND (bookmarks.collection_id = sqlc.narg(collection_id)::uuid OR sqlc.narg(collection_id)::uuid IS NULL)
+    AND ((bookmarks.created_at, bookmarks.chirp_id) < (sqlc.narg(before)::timestamp, sqlc.arg(before_id)::uuid)
+        OR sqlc.narg(before)::timestamp IS NULL)
+    AND chirps.status = 'published' AND chirps.deleted_at IS NULL AND users.deleted_at IS NULL
+    AND chirp_visible(
+        chirps.user_id, chirps.visibility, chirps.hidden_at IS NOT NULL, users.shadow_banned,
+        FALSE, sqlc.arg(user_id), FALSE
+    )
+ORDER BY bookmarks.created_at DESC, bookmarks.chirp_id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: CreateBookmarkCollection :one
+INSERT INTO bookmark_collections (id, created_at, updated_at, user_id, name)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2
+)
+RETURNING *;
+
+-- name: GetBookmarkCollection :one
+SELECT * FROM bookmark_collections WHERE id = $1 AND user_id = $2;
+
+-- name: GetBookmarkCollections :many
+SELECT * FROM bookmark_collections
+WHERE user_id = $1
+ORDER BY name ASC;
+
+-- name: RenameBookmarkCollection :one
+UPDATE bookmark_collections
+SET
+    updated_at = NOW(),
+    name = $1
+WHERE id = $2 AND user_id = $3
+RETURNING *;
+
+-- name: DeleteBookmarkCollection :execrows
+DELETE FROM bookmark_collections WHERE id = $1 AND user_id = $2;
@@ -0,0 +1,19 @@
+-- name: RecordChirpViews :exec
+INSERT INTO chirp_views (chirp_id, bucket, views)
+SELECT chirps.id, sqlc.arg(bucket)::timestamp, counts.views
+FROM unnest(sqlc.arg(chirp_ids)::uuid[], sqlc.arg(views)::bigint[]) AS counts(chirp_id, views)
+JOIN chirps ON chirps.id = counts.chirp_id
+ON CONFLICT (chirp_id, bucket) DO UPDATE
+SET views = chirp_views.views + EXCLUDED.views;
+
+-- name: GetChirpViewsByAuthor :many
+SELECT
+    chirp_views.chirp_id,
+    date_trunc(sqlc.arg(unit)::text, chirp_views.bucket)::timestamp AS bucket,
+    SUM(chirp_views.views)::bigint AS views
+FROM chirp_views
+JOIN chirps ON chirps.id = chirp_views.chirp_id
+WHERE chirps.user_id = sqlc.arg(user_id) AND chirps.deleted_at IS NULL
+    AND chirp_views.bucket >= sqlc.arg(since)::timestamp
+GROUP BY chirp_views.chirp_id, 2
+ORDER BY chirp_views.chirp_id, 2;
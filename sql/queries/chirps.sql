@@ -1,22 +1,160 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, visibility, status, scheduled_at)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5
 )
 RETURNING *;
 
 -- name: GetChirps :many
-SELECT * FROM chirps ORDER BY created_at ASC;
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.status = 'published' AND chirps.deleted_at IS NULL AND users.deleted_at IS NULL
+    AND chirp_visible(
+        chirps.user_id, chirps.visibility, chirps.hidden_at IS NOT NULL, users.shadow_banned,
+        sqlc.arg(include_hidden)::bool, sqlc.narg(viewer_id)::uuid, TRUE
+    )
+ORDER BY chirps.created_at ASC;
 
 -- name: GetChirpsByUserID :many
-SELECT * FROM chirps WHERE user_id = $1 ORDER BY created_at ASC;
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = sqlc.arg(user_id) AND chirps.status = 'published' AND chirps.deleted_at IS NULL AND users.deleted_at IS NULL
+    AND chirp_visible(
+        chirps.user_id, chirps.visibility, chirps.hidden_at IS NOT NULL, users.shadow_banned,
+        sqlc.arg(include_hidden)::bool, sqlc.narg(viewer_id)::uuid, FALSE
+    )
+ORDER BY chirps.created_at ASC;
 
 -- name: GetChirp :one
-SELECT * FROM chirps WHERE id = $1;
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = sqlc.arg(id) AND chirps.status = 'published' AND chirps.deleted_at IS NULL AND users.deleted_at IS NULL
+    AND chirp_visible(
+        chirps.user_id, chirps.visibility, chirps.hidden_at IS NOT NULL, users.shadow_banned,
+        sqlc.arg(include_hidden)::bool, sqlc.narg(viewer_id)::uuid, FALSE
+    );
+
+-- name: GetChirpForReview :one
+SELECT * FROM chirps WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: ApproveChirp :one
+UPDATE chirps
+SET
+    updated_at = NOW(),
+    status = 'published'
+WHERE id = $1 AND status = 'pending'
+RETURNING *;
+
+-- name: HideChirp :exec
+UPDATE chirps
+SET
+    updated_at = NOW(),
+    hidden_at = NOW()
+WHERE id = $1;
+
+-- name: HasRecentDuplicateChirp :one
+SELECT EXISTS (
+    SELECT 1 FROM chirps
+    WHERE user_id = $1 AND body = $2 AND created_at > $3 AND status IN ('published', 'pending') AND deleted_at IS NULL
+);
+
+-- name: LockUserChirps :exec
+-- Held until the transaction ends, so that checking for a duplicate and
+-- inserting the chirp can't interleave with another post by the same user.
+SELECT pg_advisory_xact_lock(hashtextextended(sqlc.arg(user_id)::uuid::text, 0));
 
 -- name: DeleteChirp :exec
 DELETE FROM chirps where id = $1;
+
+-- name: SoftDeleteChirp :exec
+UPDATE chirps
+SET
+    updated_at = NOW(),
+    deleted_at = NOW()
+WHERE id = $1;
+
+-- name: RestoreChirp :one
+UPDATE chirps
+SET
+    updated_at = NOW(),
+    deleted_at = NULL
+WHERE id = $1 AND user_id = $2 AND deleted_at > $3
+RETURNING *;
+
+-- name: GetDeletedChirps :many
+SELECT * FROM chirps
+WHERE user_id = $1 AND deleted_at > $2
+ORDER BY deleted_at DESC;
+
+-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at IS NOT NULL AND deleted_at < $1;
+
+-- name: ImportChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, status)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetRecentChirpsByUser :many
+SELECT body, created_at FROM chirps
+WHERE user_id = $1 AND created_at > $2 AND status IN ('published', 'pending') AND deleted_at IS NULL
+ORDER BY created_at DESC
+LIMIT 100;
+
+-- name: GetDraftChirps :many
+SELECT * FROM chirps
+WHERE user_id = $1 AND status IN ('draft', 'scheduled')
+ORDER BY scheduled_at ASC NULLS LAST, created_at ASC;
+
+-- name: UpdateDraftChirp :one
+UPDATE chirps
+SET
+    updated_at = NOW(),
+    body = $1,
+    visibility = $2,
+    status = $3,
+    scheduled_at = $4
+WHERE id = $5 AND user_id = $6 AND status IN ('draft', 'scheduled')
+RETURNING *;
+
+-- name: DeleteDraftChirp :execrows
+DELETE FROM chirps
+WHERE id = $1 AND user_id = $2 AND status IN ('draft', 'scheduled');
+
+-- name: ClaimDueChirp :one
+SELECT * FROM chirps
+WHERE status = 'scheduled' AND scheduled_at <= $1
+ORDER BY scheduled_at ASC
+LIMIT 1
+FOR UPDATE SKIP LOCKED;
+
+-- name: PublishScheduledChirp :one
+UPDATE chirps
+SET
+    created_at = NOW(),
+    updated_at = NOW(),
+    status = $1
+WHERE id = $2
+RETURNING *;
+
+-- name: RescheduleChirp :exec
+UPDATE chirps
+SET
+    updated_at = NOW(),
+    status = $1,
+    scheduled_at = $2
+WHERE id = $3;
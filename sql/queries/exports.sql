@@ -0,0 +1,48 @@
+-- name: CreateDataExport :one
+INSERT INTO data_exports (id, created_at, updated_at, user_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1
+)
+RETURNING *;
+
+-- name: GetDataExport :one
+SELECT * FROM data_exports WHERE id = $1;
+
+-- name: MarkDataExportReady :execrows
+UPDATE data_exports
+SET
+    updated_at = NOW(),
+    status = 'ready',
+    file_path = $1,
+    expires_at = $2
+WHERE id = $3 AND status = 'pending';
+
+-- name: MarkDataExportFailed :exec
+UPDATE data_exports
+SET
+    updated_at = NOW(),
+    status = 'failed'
+WHERE id = $1;
+
+-- name: FailStaleDataExports :execrows
+-- Exports are built in the background by the server that was asked for them.
+-- One that is still pending long after was lost in a restart or crash.
+UPDATE data_exports
+SET
+    updated_at = NOW(),
+    status = 'failed'
+WHERE status = 'pending' AND created_at < $1;
+
+-- name: GetDataExportsOfDeletedUsers :many
+SELECT data_exports.* FROM data_exports
+JOIN users ON users.id = data_exports.user_id
+WHERE users.deleted_at IS NOT NULL AND users.deleted_at < $1;
+
+-- name: GetExpiredDataExports :many
+SELECT * FROM data_exports WHERE expires_at < $1;
+
+-- name: DeleteDataExport :exec
+DELETE FROM data_exports WHERE id = $1;
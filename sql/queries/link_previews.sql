@@ -0,0 +1,21 @@
+-- name: UpsertLinkPreview :exec
+INSERT INTO link_previews (url, fetched_at, ok, title, description, image_url, site_name)
+VALUES ($1, NOW(), $2, $3, $4, $5, $6)
+ON CONFLICT (url) DO UPDATE
+SET
+    fetched_at = NOW(),
+    ok = EXCLUDED.ok,
+    title = EXCLUDED.title,
+    description = EXCLUDED.description,
+    image_url = EXCLUDED.image_url,
+    site_name = EXCLUDED.site_name;
+
+-- name: HasFreshLinkPreview :one
+SELECT EXISTS (
+    SELECT 1 FROM link_previews
+    WHERE url = $1 AND fetched_at > $2
+);
+
+-- name: GetLinkPreviews :many
+SELECT * FROM link_previews
+WHERE ok AND url = ANY(sqlc.arg(urls)::text[]);
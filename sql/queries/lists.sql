@@ -0,0 +1,65 @@
+-- name: CreateList :one
+INSERT INTO lists (id, created_at, updated_at, owner_id, name, description, private)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetList :one
+SELECT * FROM lists WHERE id = $1;
+
+-- name: GetListsByOwner :many
+SELECT * FROM lists
+WHERE owner_id = sqlc.arg(owner_id) AND (NOT private OR sqlc.arg(include_private)::bool)
+ORDER BY created_at ASC;
+
+-- name: UpdateList :one
+UPDATE lists
+SET
+    updated_at = NOW(),
+    name = $1,
+    description = $2,
+    private = $3
+WHERE id = $4 AND owner_id = $5
+RETURNING *;
+
+-- name: DeleteList :execrows
+DELETE FROM lists WHERE id = $1 AND owner_id = $2;
+
+-- name: AddListMember :exec
+INSERT INTO list_members (list_id, user_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveListMember :exec
+DELETE FROM list_members WHERE list_id = $1 AND user_id = $2;
+
+-- name: CountListMembers :one
+SELECT COUNT(*) FROM list_members WHERE list_id = $1;
+
+-- name: GetListMembers :many
+SELECT list_members.* FROM list_members
+JOIN users ON users.id = list_members.user_id
+WHERE list_members.list_id = $1 AND users.deleted_at IS NULL
+ORDER BY list_members.created_at ASC;
+
+-- name: GetListChirps :many
+SELECT chirps.* FROM chirps
+JOIN list_members ON list_members.user_id = chirps.user_id
+JOIN users ON users.id = chirps.user_id
+WHERE list_members.list_id = sqlc.arg(list_id)
+    AND ((chirps.created_at, chirps.id) < (sqlc.narg(before)::timestamp, sqlc.arg(before_id)::uuid)
+        OR sqlc.narg(before)::timestamp IS NULL)
+    AND chirps.status = 'published' AND chirps.deleted_at IS NULL AND users.deleted_at IS NULL
+    AND chirp_visible(
+        chirps.user_id, chirps.visibility, chirps.hidden_at IS NOT NULL, users.shadow_banned,
+        FALSE, sqlc.narg(viewer_id)::uuid, TRUE
+    )
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,12 @@
+-- name: GetModerationTerms :many
+SELECT * FROM moderation_terms ORDER BY term ASC;
+
+-- name: CreateChirpFlag :exec
+INSERT INTO chirp_flags (id, created_at, chirp_id, rule, term)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+);
@@ -0,0 +1,46 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, created_at, updated_at, chirp_id, reporter_id, reason, details)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetReport :one
+SELECT * FROM reports WHERE id = $1;
+
+-- name: ListOpenReports :many
+SELECT reports.*, chirps.body AS chirp_body, chirps.user_id AS author_id
+FROM reports
+LEFT JOIN chirps ON chirps.id = reports.chirp_id
+WHERE reports.status = 'open'
+ORDER BY reports.created_at ASC;
+
+-- name: ResolveReport :execrows
+UPDATE reports
+SET
+    updated_at = NOW(),
+    status = $1
+WHERE id = $2 AND status = 'open';
+
+-- name: CreateModerationAction :one
+INSERT INTO moderation_actions (id, created_at, moderator_id, report_id, chirp_id, target_user_id, action, note)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING *;
+
+-- name: ListModerationActions :many
+SELECT * FROM moderation_actions ORDER BY created_at DESC;
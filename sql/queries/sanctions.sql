@@ -0,0 +1,14 @@
+-- name: CreateUserSanction :exec
+INSERT INTO user_sanctions (id, created_at, user_id, moderator_id, action, reason, expires_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+);
+
+-- name: GetUserSanctions :many
+SELECT * FROM user_sanctions WHERE user_id = $1 ORDER BY created_at DESC;
@@ -18,3 +18,13 @@ SET
     updated_at = $1,
     revoked_at = $1
 WHERE id = $2;
+
+-- name: RevokeUserTokens :exec
+UPDATE refresh_tokens
+SET
+    updated_at = $1,
+    revoked_at = $1
+WHERE user_id = $2 AND revoked_at IS NULL;
+
+-- name: GetTokensByUserID :many
+SELECT * FROM refresh_tokens WHERE user_id = $1 ORDER BY created_at ASC;
@@ -15,6 +15,9 @@ DELETE FROM users;
 -- name: GetUser :one
 SELECT * FROM users WHERE email = $1;
 
+-- name: GetUserByID :one
+SELECT * FROM users WHERE id = $1;
+
 -- name: UpdateUser :one
 UPDATE users
 SET
@@ -29,3 +32,67 @@ UPDATE users
 SET
     is_chirpy_red = $1
 WHERE id = $2;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET
+    hashed_password = $1
+WHERE id = $2;
+
+-- name: SoftDeleteUser :exec
+UPDATE users
+SET
+    updated_at = NOW(),
+    deleted_at = NOW()
+WHERE id = $1;
+
+-- name: RestoreUser :one
+UPDATE users
+SET
+    updated_at = NOW(),
+    deleted_at = NULL
+WHERE id = $1
+RETURNING *;
+
+-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deleted_at IS NOT NULL AND deleted_at < sqlc.arg(deleted_at)
+    AND NOT (id = ANY(sqlc.arg(keep_ids)::uuid[]));
+
+-- name: SetUserModerator :exec
+UPDATE users
+SET
+    updated_at = NOW(),
+    is_moderator = $1
+WHERE id = $2;
+
+-- name: SuspendUser :exec
+UPDATE users
+SET
+    updated_at = NOW(),
+    suspended_until = $1,
+    suspension_reason = $2
+WHERE id = $3;
+
+-- name: UnsuspendUser :exec
+UPDATE users
+SET
+    updated_at = NOW(),
+    suspended_until = NULL,
+    suspension_reason = ''
+WHERE id = $1;
+
+-- name: SetUserShadowBan :exec
+UPDATE users
+SET
+    updated_at = NOW(),
+    shadow_banned = $1,
+    shadow_ban_reason = $2
+WHERE id = $3;
+
+-- name: SetUserAvatar :exec
+UPDATE users
+SET
+    updated_at = NOW(),
+    avatar_hash = $1
+WHERE id = $2;
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE moderation_terms (
+    term TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    action TEXT NOT NULL
+);
+
+CREATE TABLE chirp_flags (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    chirp_id UUID NOT NULL,
+    rule TEXT NOT NULL,
+    term TEXT NOT NULL,
+    CONSTRAINT fk_chirps FOREIGN KEY (chirp_id)
+    REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE chirp_flags;
+DROP TABLE moderation_terms;
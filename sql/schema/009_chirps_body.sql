@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE chirps
+DROP CONSTRAINT chirps_body_key;
+
+CREATE INDEX idx_chirps_user_created ON chirps (user_id, created_at);
+
+-- +goose Down
+DROP INDEX idx_chirps_user_created;
+
+ALTER TABLE chirps
+ADD CONSTRAINT chirps_body_key UNIQUE (body);
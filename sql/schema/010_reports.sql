@@ -0,0 +1,48 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN is_moderator BOOLEAN NOT NULL DEFAULT false,
+ADD COLUMN suspended_until TIMESTAMP;
+
+ALTER TABLE chirps
+ADD COLUMN hidden_at TIMESTAMP;
+
+CREATE TABLE reports (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    chirp_id UUID,
+    reporter_id UUID,
+    reason TEXT NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'open',
+    CONSTRAINT fk_chirps FOREIGN KEY (chirp_id)
+    REFERENCES chirps(id) ON DELETE SET NULL,
+    CONSTRAINT fk_users FOREIGN KEY (reporter_id)
+    REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE TABLE moderation_actions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    moderator_id UUID,
+    report_id UUID,
+    chirp_id UUID,
+    target_user_id UUID,
+    action TEXT NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    CONSTRAINT fk_users FOREIGN KEY (moderator_id)
+    REFERENCES users(id) ON DELETE SET NULL,
+    CONSTRAINT fk_reports FOREIGN KEY (report_id)
+    REFERENCES reports(id) ON DELETE SET NULL
+);
+
+-- +goose Down
+DROP TABLE moderation_actions;
+DROP TABLE reports;
+
+ALTER TABLE chirps
+DROP COLUMN hidden_at;
+
+ALTER TABLE users
+DROP COLUMN suspended_until,
+DROP COLUMN is_moderator;
@@ -0,0 +1,27 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN suspension_reason TEXT NOT NULL DEFAULT '',
+ADD COLUMN shadow_banned BOOLEAN NOT NULL DEFAULT false,
+ADD COLUMN shadow_ban_reason TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE user_sanctions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    moderator_id UUID,
+    action TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMP,
+    CONSTRAINT fk_users FOREIGN KEY (user_id)
+    REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_moderators FOREIGN KEY (moderator_id)
+    REFERENCES users(id) ON DELETE SET NULL
+);
+
+-- +goose Down
+DROP TABLE user_sanctions;
+
+ALTER TABLE users
+DROP COLUMN shadow_ban_reason,
+DROP COLUMN shadow_banned,
+DROP COLUMN suspension_reason;
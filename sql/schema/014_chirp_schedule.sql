@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN status TEXT NOT NULL DEFAULT 'published'
+CHECK (status IN ('draft', 'scheduled', 'published')),
+ADD COLUMN scheduled_at TIMESTAMP;
+
+CREATE INDEX idx_chirps_scheduled ON chirps (scheduled_at) WHERE status = 'scheduled';
+
+-- +goose Down
+DROP INDEX idx_chirps_scheduled;
+
+ALTER TABLE chirps
+DROP COLUMN scheduled_at,
+DROP COLUMN status;
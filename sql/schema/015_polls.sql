@@ -0,0 +1,39 @@
+-- +goose Up
+CREATE TABLE polls (
+    chirp_id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    closes_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_chirps FOREIGN KEY (chirp_id)
+    REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+CREATE TABLE poll_options (
+    id UUID PRIMARY KEY,
+    chirp_id UUID NOT NULL,
+    position INTEGER NOT NULL,
+    label TEXT NOT NULL,
+    UNIQUE (chirp_id, position),
+    CONSTRAINT fk_polls FOREIGN KEY (chirp_id)
+    REFERENCES polls(chirp_id) ON DELETE CASCADE
+);
+
+CREATE TABLE poll_votes (
+    chirp_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    option_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (chirp_id, user_id),
+    CONSTRAINT fk_polls FOREIGN KEY (chirp_id)
+    REFERENCES polls(chirp_id) ON DELETE CASCADE,
+    CONSTRAINT fk_poll_options FOREIGN KEY (option_id)
+    REFERENCES poll_options(id) ON DELETE CASCADE,
+    CONSTRAINT fk_users FOREIGN KEY (user_id)
+    REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_poll_votes_option ON poll_votes (option_id);
+
+-- +goose Down
+DROP TABLE poll_votes;
+DROP TABLE poll_options;
+DROP TABLE polls;
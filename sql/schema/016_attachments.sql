@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE attachments (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    chirp_id UUID,
+    content_type TEXT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    size INTEGER NOT NULL,
+    alt_text TEXT NOT NULL DEFAULT '',
+    position INTEGER NOT NULL DEFAULT 0,
+    CONSTRAINT fk_users FOREIGN KEY (user_id)
+    REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_chirps FOREIGN KEY (chirp_id)
+    REFERENCES chirps(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_attachments_chirp ON attachments (chirp_id);
+
+-- +goose Down
+DROP TABLE attachments;
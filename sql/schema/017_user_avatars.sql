@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN avatar_hash TEXT;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN avatar_hash;
@@ -0,0 +1,31 @@
+-- +goose Up
+CREATE TABLE bookmark_collections (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    name TEXT NOT NULL,
+    UNIQUE (user_id, name),
+    CONSTRAINT fk_users FOREIGN KEY (user_id)
+    REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE bookmarks (
+    user_id UUID NOT NULL,
+    chirp_id UUID NOT NULL,
+    collection_id UUID,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id),
+    CONSTRAINT fk_users FOREIGN KEY (user_id)
+    REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_chirps FOREIGN KEY (chirp_id)
+    REFERENCES chirps(id) ON DELETE CASCADE,
+    CONSTRAINT fk_collections FOREIGN KEY (collection_id)
+    REFERENCES bookmark_collections(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_bookmarks_user_created ON bookmarks (user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE bookmarks;
+DROP TABLE bookmark_collections;
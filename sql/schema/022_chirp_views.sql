@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE chirp_views (
+    chirp_id UUID NOT NULL,
+    bucket TIMESTAMP NOT NULL,
+    views BIGINT NOT NULL,
+    PRIMARY KEY (chirp_id, bucket),
+    CONSTRAINT fk_chirps FOREIGN KEY (chirp_id)
+    REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE chirp_views;
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE chirps
+DROP CONSTRAINT chirps_status_check,
+ADD CONSTRAINT chirps_status_check
+CHECK (status IN ('draft', 'scheduled', 'published', 'pending'));
+
+-- +goose Down
+UPDATE chirps SET status = 'published' WHERE status = 'pending';
+
+ALTER TABLE chirps
+DROP CONSTRAINT chirps_status_check,
+ADD CONSTRAINT chirps_status_check
+CHECK (status IN ('draft', 'scheduled', 'published'));
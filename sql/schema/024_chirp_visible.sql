@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+-- chirp_visible is the one definition of who may see a chirp, used by every
+-- query that lists chirps. The stream checks its events with the same rule in
+-- internal/visibility; change both together.
+--
+-- Hidden chirps are shown to moderators only, not even to their author, and
+-- chirps of shadow-banned users to moderators and the author. Blocks work
+-- both ways. in_feed is set for feeds mixing many authors: they leave out
+-- unlisted chirps and muted authors, while looking up a chirp, an author or a
+-- bookmark shows unlisted chirps to anyone logged in and ignores mutes.
+CREATE FUNCTION chirp_visible(
+    author_id UUID,
+    visibility TEXT,
+    hidden BOOLEAN,
+    shadow_banned BOOLEAN,
+    moderator BOOLEAN,
+    viewer_id UUID,
+    in_feed BOOLEAN
+) RETURNS BOOLEAN
+LANGUAGE sql STABLE
+AS $$
+    SELECT CASE
+        WHEN hidden AND NOT moderator THEN FALSE
+        WHEN author_id = viewer_id THEN TRUE
+        WHEN shadow_banned AND NOT moderator THEN FALSE
+        WHEN EXISTS (
+            SELECT 1 FROM blocks
+            WHERE (blocks.blocker_id = author_id AND blocks.blocked_id = viewer_id)
+                OR (blocks.blocker_id = viewer_id AND blocks.blocked_id = author_id)
+        ) THEN FALSE
+        WHEN in_feed AND EXISTS (
+            SELECT 1 FROM mutes
+            WHERE mutes.muter_id = viewer_id AND mutes.muted_id = author_id
+        ) THEN FALSE
+        ELSE visibility = 'public'
+            OR (visibility = 'unlisted' AND NOT in_feed AND viewer_id IS NOT NULL)
+            OR (visibility = 'followers' AND EXISTS (
+                SELECT 1 FROM follows
+                WHERE follows.follower_id = viewer_id AND follows.followee_id = author_id
+            ))
+    END
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION chirp_visible(UUID, TEXT, BOOLEAN, BOOLEAN, BOOLEAN, UUID, BOOLEAN);
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/database"
+	"github.com/hugermuger/chirpy/internal/stream"
+	"github.com/hugermuger/chirpy/internal/visibility"
+)
+
+const (
+	streamHistory   = 1024
+	streamBuffer    = 64
+	streamHeartbeat = 15 * time.Second
+	// streamRetry tells clients how long to wait before reconnecting, in
+	// milliseconds.
+	streamRetry = 3000
+)
+
+// publishChirp announces a newly visible chirp on the stream. Polls and media
+// are included as an anonymous viewer would see them. Chirps that aren't
+// published, or that a moderator has hidden, aren't announced at all.
+func (cfg *apiConfig) publishChirp(ctx context.Context, chirp database.Chirp, authorOnly bool) {
+	if chirp.Status != "published" || chirp.HiddenAt.Valid {
+		return
+	}
+	jsonChirps := []Chirp{jsonChirp(chirp)}
+	err := cfg.expandChirps(ctx, uuid.NullUUID{}, jsonChirps)
+	if err != nil {
+		log.Printf("Couldn't get details of chirp %s for the stream: %s", chirp.ID, err)
+	}
+	data, err := json.Marshal(jsonChirps[0])
+	if err != nil {
+		log.Printf("Couldn't encode chirp %s for the stream: %s", chirp.ID, err)
+		return
+	}
+
+	cfg.broadcast(stream.Event{
+		Type:       stream.TypeChirp,
+		ChirpID:    chirp.ID,
+		AuthorID:   chirp.UserID,
+		Visibility: chirp.Visibility,
+		AuthorOnly: authorOnly,
+		Data:       data,
+	})
+}
+
+// publishDeletion tells the stream a chirp is gone, whether it was deleted
+// or hidden by a moderator. Like publishChirp, authorOnly keeps the event of
+// a shadow-banned author to the author's own streams.
+func (cfg *apiConfig) publishDeletion(chirp database.Chirp, authorOnly bool) {
+	data, err := json.Marshal(struct {
+		ID uuid.UUID `json:"id"`
+	}{ID: chirp.ID})
+	if err != nil {
+		log.Printf("Couldn't encode deletion of chirp %s for the stream: %s", chirp.ID, err)
+		return
+	}
+
+	cfg.broadcast(stream.Event{
+		Type:       stream.TypeDelete,
+		ChirpID:    chirp.ID,
+		AuthorID:   chirp.UserID,
+		Visibility: chirp.Visibility,
+		AuthorOnly: authorOnly,
+		Data:       data,
+	})
+}
+
+// publishFollow notifies a user that someone started following them.
+func (cfg *apiConfig) publishFollow(followerID, followeeID uuid.UUID) {
+	data, err := json.Marshal(struct {
+		FollowerID uuid.UUID `json:"follower_id"`
+	}{FollowerID: followerID})
+	if err != nil {
+		log.Printf("Couldn't encode follow notification for the stream: %s", err)
+		return
+	}
+
+	cfg.broadcast(stream.Event{
+		Type:        stream.TypeFollow,
+		AuthorID:    followerID,
+		RecipientID: followeeID,
+		Data:        data,
+	})
+}
+
+func (cfg *apiConfig) broadcast(event stream.Event) {
+	err := cfg.broadcaster.Broadcast(event)
+	if err != nil {
+		log.Printf("Couldn't broadcast %s event: %s", event.Type, err)
+	}
+}
+
+// streamFilter decides which events a stream shows, by the same rule as the
+// chirp listings. The viewer's follows, blocks and mutes are read when the
+// stream starts. Hidden chirps are never published and events marked
+// AuthorOnly go to the author alone, so streams don't show moderators
+// anything more than other users.
+type streamFilter struct {
+	viewer   visibility.Viewer
+	authorID uuid.NullUUID
+	timeline bool
+}
+
+// newStreamFilter returns a filter for viewerID, or for a logged out viewer.
+// Its relations are filled in by loadStreamRelations.
+func newStreamFilter(viewerID uuid.NullUUID) streamFilter {
+	return streamFilter{
+		viewer: visibility.Viewer{
+			ID:      viewerID,
+			Follows: map[uuid.UUID]bool{},
+			Blocks:  map[uuid.UUID]bool{},
+			Mutes:   map[uuid.UUID]bool{},
+		},
+	}
+}
+
+func (f *streamFilter) allows(event stream.Event) bool {
+	if event.Type != stream.TypeChirp && event.Type != stream.TypeDelete {
+		return false
+	}
+	own := f.viewer.ID.Valid && event.AuthorID == f.viewer.ID.UUID
+	switch {
+	case f.authorID.Valid && event.AuthorID != f.authorID.UUID:
+		return false
+	case f.timeline && !own && !f.viewer.Follows[event.AuthorID]:
+		return false
+	}
+
+	// The author channel is a lookup of one author, like GetChirpsByUserID;
+	// the global feed and timeline are feeds.
+	return f.viewer.CanSee(visibility.Chirp{
+		AuthorID:     event.AuthorID,
+		Visibility:   event.Visibility,
+		ShadowBanned: event.AuthorOnly,
+	}, !f.authorID.Valid)
+}
+
+// streamChirps pushes chirp events as Server-Sent Events. By default it
+// streams the global feed; author_id narrows it to one author and
+// feed=timeline to the caller and the accounts they follow. Clients resume
+// with the Last-Event-ID header; if the events since then are no longer
+// kept they get a reset event and should refetch. A client that can't keep
+// up is disconnected and can resume the same way.
+func (cfg *apiConfig) streamChirps(w http.ResponseWriter, r *http.Request) {
+	viewer, loggedIn := cfg.viewer(r)
+	filter := newStreamFilter(uuid.NullUUID{UUID: viewer.ID, Valid: loggedIn})
+
+	switch r.URL.Query().Get("feed") {
+	case "", "global":
+	case "timeline":
+		if !loggedIn {
+			respondWithError(w, http.StatusUnauthorized, "The timeline needs a valid token", nil)
+			return
+		}
+		filter.timeline = true
+	default:
+		respondWithError(w, http.StatusBadRequest, "feed must be global or timeline", nil)
+		return
+	}
+
+	if s := r.URL.Query().Get("author_id"); s != "" {
+		authorID, err := uuid.Parse(s)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid author ID", err)
+			return
+		}
+		filter.authorID = uuid.NullUUID{UUID: authorID, Valid: true}
+	}
+
+	if loggedIn {
+		err := cfg.loadStreamRelations(r.Context(), viewer.ID, &filter)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get user relations", err)
+			return
+		}
+	}
+
+	lastID := uint64(0)
+	if s := r.Header.Get("Last-Event-ID"); s != "" {
+		// An ID this server didn't hand out is treated as too old.
+		lastID, _ = strconv.ParseUint(s, 10, 64)
+		lastID = max(lastID, 1)
+	}
+
+	sub, missed, complete := cfg.hub.Subscribe(lastID, filter.allows)
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry)
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, event := range missed {
+		writeStreamEvent(w, event)
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			writeStreamEvent(w, event)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (cfg *apiConfig) loadStreamRelations(ctx context.Context, viewerID uuid.UUID, filter *streamFilter) error {
+	follows, err := cfg.dbQueries.GetFolloweeIDs(ctx, viewerID)
+	if err != nil {
+		return err
+	}
+	blocks, err := cfg.dbQueries.GetBlockedUserIDs(ctx, viewerID)
+	if err != nil {
+		return err
+	}
+	mutes, err := cfg.dbQueries.GetMutedUserIDs(ctx, viewerID)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range follows {
+		filter.viewer.Follows[id] = true
+	}
+	for _, id := range blocks {
+		filter.viewer.Blocks[id] = true
+	}
+	for _, id := range mutes {
+		filter.viewer.Mutes[id] = true
+	}
+	return nil
+}
+
+func writeStreamEvent(w http.ResponseWriter, event stream.Event) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+}
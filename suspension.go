@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/database"
+)
+
+// Sanctions are applied through these helpers so every change to a user's
+// moderation state also lands in user_sanctions. q may be bound to a
+// transaction.
+
+// suspendUser blocks logins and token refreshes until the given time and
+// ends the user's sessions. Access tokens already handed out are refused by
+// validateAccessToken for as long as the suspension lasts.
+func (cfg *apiConfig) suspendUser(ctx context.Context, q *database.Queries, userID uuid.UUID, moderatorID uuid.NullUUID, until time.Time, reason string) error {
+	err := q.SuspendUser(ctx, database.SuspendUserParams{
+		SuspendedUntil:   sql.NullTime{Time: until, Valid: true},
+		SuspensionReason: reason,
+		ID:               userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = q.RevokeUserTokens(ctx, database.RevokeUserTokensParams{
+		UpdatedAt: time.Now(),
+		UserID:    userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return q.CreateUserSanction(ctx, database.CreateUserSanctionParams{
+		UserID:      userID,
+		ModeratorID: moderatorID,
+		Action:      "suspend",
+		Reason:      reason,
+		ExpiresAt:   sql.NullTime{Time: until, Valid: true},
+	})
+}
+
+func (cfg *apiConfig) unsuspendUser(ctx context.Context, q *database.Queries, userID uuid.UUID, moderatorID uuid.NullUUID, reason string) error {
+	err := q.UnsuspendUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return q.CreateUserSanction(ctx, database.CreateUserSanctionParams{
+		UserID:      userID,
+		ModeratorID: moderatorID,
+		Action:      "unsuspend",
+		Reason:      reason,
+	})
+}
+
+// warnUser records a warning against a user. It changes nothing else, but
+// shows up in the user's sanction history for moderators deciding on the
+// next report.
+func (cfg *apiConfig) warnUser(ctx context.Context, q *database.Queries, userID uuid.UUID, moderatorID uuid.NullUUID, reason string) error {
+	return q.CreateUserSanction(ctx, database.CreateUserSanctionParams{
+		UserID:      userID,
+		ModeratorID: moderatorID,
+		Action:      "warn",
+		Reason:      reason,
+	})
+}
+
+// setShadowBan makes a user's chirps visible only to themselves (and to
+// moderators), or lifts that again.
+func (cfg *apiConfig) setShadowBan(ctx context.Context, q *database.Queries, userID uuid.UUID, moderatorID uuid.NullUUID, banned bool, reason string) error {
+	banReason := reason
+	action := "shadow_ban"
+	if !banned {
+		banReason = ""
+		action = "lift_shadow_ban"
+	}
+
+	err := q.SetUserShadowBan(ctx, database.SetUserShadowBanParams{
+		ShadowBanned:    banned,
+		ShadowBanReason: banReason,
+		ID:              userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return q.CreateUserSanction(ctx, database.CreateUserSanctionParams{
+		UserID:      userID,
+		ModeratorID: moderatorID,
+		Action:      action,
+		Reason:      reason,
+	})
+}
+
+func isSuspended(user database.User) bool {
+	return user.SuspendedUntil.Valid && user.SuspendedUntil.Time.After(time.Now())
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/auth"
+	"github.com/hugermuger/chirpy/internal/database"
+)
+
+var (
+	errAccountDeleted   = errors.New("account is deleted")
+	errAccountSuspended = errors.New("account is suspended")
+)
+
+// validateAccessToken is auth.ValidateJWT plus a check that the account can
+// still use its tokens. Access tokens can't be revoked, so this is what
+// stops them working once the account is deleted or suspended.
+func (cfg *apiConfig) validateAccessToken(ctx context.Context, token string) (uuid.UUID, error) {
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	_, err = cfg.activeUser(ctx, userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return userID, nil
+}
+
+// activeUser returns the user if their account may act on their behalf.
+func (cfg *apiConfig) activeUser(ctx context.Context, userID uuid.UUID) (database.User, error) {
+	user, err := cfg.dbQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return database.User{}, err
+	}
+	if user.DeletedAt.Valid {
+		return database.User{}, errAccountDeleted
+	}
+	if isSuspended(user) {
+		return database.User{}, errAccountSuspended
+	}
+	return user, nil
+}
+
+// viewer returns the user behind the request's access token, if there is a
+// valid one. Public endpoints use it to tailor what they show.
+func (cfg *apiConfig) viewer(r *http.Request) (database.User, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return database.User{}, false
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		return database.User{}, false
+	}
+
+	user, err := cfg.activeUser(r.Context(), userID)
+	if err != nil {
+		return database.User{}, false
+	}
+	return user, true
+}
+
+// requireModerator responds with an error and returns false unless the
+// request comes from a moderator.
+func (cfg *apiConfig) requireModerator(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing Token", err)
+		return database.User{}, false
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+		return database.User{}, false
+	}
+
+	user, err := cfg.activeUser(r.Context(), userID)
+	if err != nil || !user.IsModerator {
+		respondWithError(w, http.StatusForbidden, "Moderators only", err)
+		return database.User{}, false
+	}
+	return user, true
+}
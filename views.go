@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/database"
+	"github.com/hugermuger/chirpy/internal/impressions"
+	"github.com/hugermuger/chirpy/internal/ratelimit"
+)
+
+// viewBucket is the finest time bucket analytics can report views in.
+const viewBucket = time.Hour
+
+// maxViewsPerRequest is how many chirps of one response count as viewed.
+// GET /api/chirps returns every chirp, and a client only shows the first
+// page of them.
+const maxViewsPerRequest = defaultPageSize
+
+// recordViews counts an impression for each of the first chirps served to
+// the viewer, in the order they are served. Viewers are told apart by user
+// ID or, when logged out, by IP address, and each counts once per chirp and
+// bucket. Authors looking at their own chirps don't count.
+func (cfg *apiConfig) recordViews(r *http.Request, viewerID uuid.NullUUID, chirps []database.Chirp) {
+	chirps = chirps[:min(len(chirps), maxViewsPerRequest)]
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		if viewerID.Valid && chirp.UserID == viewerID.UUID {
+			continue
+		}
+		ids = append(ids, chirp.ID)
+	}
+
+	viewer := "ip:" + ratelimit.ClientIP(r, cfg.trustedProxies)
+	if viewerID.Valid {
+		viewer = "user:" + viewerID.UUID.String()
+	}
+	cfg.views.Record(viewer, ids...)
+}
+
+// runViewFlusher writes the counted views to the database every interval,
+// one statement per time bucket. Views counted since the last flush are lost
+// if the server stops.
+func (cfg *apiConfig) runViewFlusher(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cfg.flushViews(context.Background())
+	}
+}
+
+func (cfg *apiConfig) flushViews(ctx context.Context) {
+	counts := cfg.views.Drain()
+	failed := map[impressions.Key]int64{}
+	for bucket, batch := range impressions.ByBucket(counts) {
+		err := cfg.dbQueries.RecordChirpViews(ctx, database.RecordChirpViewsParams{
+			Bucket:   bucket,
+			ChirpIds: batch.ChirpIDs,
+			Views:    batch.Views,
+		})
+		if err != nil {
+			log.Printf("Couldn't save chirp views: %s", err)
+			for i, id := range batch.ChirpIDs {
+				failed[impressions.Key{ChirpID: id, Bucket: bucket}] = batch.Views[i]
+			}
+		}
+	}
+	cfg.views.Restore(failed)
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/google/uuid"
+	"github.com/hugermuger/chirpy/internal/auth"
+	"github.com/hugermuger/chirpy/internal/stream"
+)
+
+const (
+	wsAuthTimeout  = 10 * time.Second
+	wsWriteTimeout = 10 * time.Second
+	wsPingInterval = 30 * time.Second
+	// wsReauthNotice is how long before the token expires the client is
+	// asked for a new one.
+	wsReauthNotice     = 2 * time.Minute
+	wsReadLimit        = 4096
+	wsMaxSubscriptions = 16
+)
+
+// wsClientMessage is a message from the client. Which fields are used
+// depends on Type: auth, subscribe, unsubscribe or ping.
+type wsClientMessage struct {
+	Type        string `json:"type"`
+	ID          string `json:"id"`
+	Token       string `json:"token"`
+	Channel     string `json:"channel"`
+	AuthorID    string `json:"author_id"`
+	LastEventID string `json:"last_event_id"`
+}
+
+type wsServerMessage struct {
+	Type      string          `json:"type"`
+	ID        string          `json:"id,omitempty"`
+	UserID    uuid.UUID       `json:"user_id,omitzero"`
+	ExpiresAt time.Time       `json:"expires_at,omitzero"`
+	Reset     bool            `json:"reset,omitempty"`
+	EventID   string          `json:"event_id,omitempty"`
+	Event     string          `json:"event,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// wsConn is one WebSocket connection and its subscriptions.
+type wsConn struct {
+	cfg      *apiConfig
+	conn     *websocket.Conn
+	ctx      context.Context
+	cancel   context.CancelFunc
+	userID   uuid.UUID
+	filter   streamFilter
+	reauthed chan time.Time
+
+	mu   sync.Mutex
+	subs map[string]*stream.Subscription
+}
+
+// serveWebSocket is the WebSocket API. A client authenticates with the
+// Authorization header or, since browsers can't set it, with an auth message
+// sent first. It then subscribes to any number of channels on the one
+// connection: global, timeline, author (with author_id) and notifications.
+// Events are resumed with last_event_id like the SSE stream's Last-Event-ID.
+//
+// Access tokens last an hour, so shortly before the token expires the
+// server sends a reauth message; the client answers with another auth
+// message carrying a fresh token for the same user. If it doesn't, the
+// connection is closed when the token expires.
+func (cfg *apiConfig) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	userID := uuid.Nil
+	var expiresAt time.Time
+	if token, err := auth.GetBearerToken(r.Header); err == nil {
+		userID, expiresAt, err = auth.ValidateJWTExpiry(token, cfg.secret)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Couldn't validate Token", err)
+			return
+		}
+	}
+
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		// Accept has already written the response.
+		return
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(wsReadLimit)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	if userID == uuid.Nil {
+		userID, expiresAt, err = readWebSocketAuth(ctx, conn, cfg.secret)
+		if err != nil {
+			conn.Close(websocket.StatusPolicyViolation, "authentication failed")
+			return
+		}
+	}
+
+	c := &wsConn{
+		cfg:      cfg,
+		conn:     conn,
+		ctx:      ctx,
+		cancel:   cancel,
+		userID:   userID,
+		filter:   newStreamFilter(uuid.NullUUID{UUID: userID, Valid: true}),
+		reauthed: make(chan time.Time, 1),
+		subs:     map[string]*stream.Subscription{},
+	}
+	defer c.closeSubscriptions()
+
+	_, err = cfg.activeUser(ctx, userID)
+	if err != nil {
+		conn.Close(websocket.StatusPolicyViolation, "authentication failed")
+		return
+	}
+	err = cfg.loadStreamRelations(ctx, userID, &c.filter)
+	if err != nil {
+		conn.Close(websocket.StatusInternalError, "couldn't get user relations")
+		return
+	}
+
+	c.send(wsServerMessage{Type: "authenticated", UserID: userID, ExpiresAt: expiresAt})
+	go c.keepAlive(expiresAt)
+
+	for {
+		var msg wsClientMessage
+		err := wsjson.Read(ctx, conn, &msg)
+		if err != nil {
+			return
+		}
+		c.handle(msg)
+	}
+}
+
+// readWebSocketAuth waits for the auth message of a client that didn't
+// send a token with the handshake.
+func readWebSocketAuth(ctx context.Context, conn *websocket.Conn, secret string) (uuid.UUID, time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, wsAuthTimeout)
+	defer cancel()
+
+	var msg wsClientMessage
+	err := wsjson.Read(ctx, conn, &msg)
+	if err != nil {
+		return uuid.Nil, time.Time{}, err
+	}
+	if msg.Type != "auth" {
+		return uuid.Nil, time.Time{}, errors.New("first message must be auth")
+	}
+	return auth.ValidateJWTExpiry(msg.Token, secret)
+}
+
+func (c *wsConn) handle(msg wsClientMessage) {
+	switch msg.Type {
+	case "ping":
+		c.send(wsServerMessage{Type: "pong", ID: msg.ID})
+	case "auth":
+		c.reauth(msg)
+	case "subscribe":
+		c.subscribe(msg)
+	case "unsubscribe":
+		c.mu.Lock()
+		sub, ok := c.subs[msg.ID]
+		delete(c.subs, msg.ID)
+		c.mu.Unlock()
+		if !ok {
+			c.sendError(msg.ID, "Unknown subscription")
+			return
+		}
+		sub.Close()
+		c.send(wsServerMessage{Type: "unsubscribed", ID: msg.ID})
+	default:
+		c.sendError(msg.ID, "Unknown message type")
+	}
+}
+
+// reauth swaps in a fresh token, which must belong to the same user.
+func (c *wsConn) reauth(msg wsClientMessage) {
+	userID, expiresAt, err := auth.ValidateJWTExpiry(msg.Token, c.cfg.secret)
+	if err != nil {
+		c.sendError(msg.ID, "Couldn't validate Token")
+		return
+	}
+	if userID != c.userID {
+		c.sendError(msg.ID, "Token is for a different user")
+		return
+	}
+	_, err = c.cfg.activeUser(c.ctx, userID)
+	if err != nil {
+		c.sendError(msg.ID, "Couldn't find user")
+		return
+	}
+
+	select {
+	case <-c.reauthed:
+	default:
+	}
+	c.reauthed <- expiresAt
+	c.send(wsServerMessage{Type: "authenticated", ID: msg.ID, UserID: userID, ExpiresAt: expiresAt})
+}
+
+func (c *wsConn) subscribe(msg wsClientMessage) {
+	if msg.ID == "" {
+		c.sendError("", "Subscriptions need an id")
+		return
+	}
+
+	var allows func(stream.Event) bool
+	switch msg.Channel {
+	case "global":
+		filter := c.filter
+		allows = filter.allows
+	case "timeline":
+		filter := c.filter
+		filter.timeline = true
+		allows = filter.allows
+	case "author":
+		authorID, err := uuid.Parse(msg.AuthorID)
+		if err != nil {
+			c.sendError(msg.ID, "Invalid author ID")
+			return
+		}
+		filter := c.filter
+		filter.authorID = uuid.NullUUID{UUID: authorID, Valid: true}
+		allows = filter.allows
+	case "notifications":
+		allows = c.allowsNotification
+	case "mentions":
+		// Users only have an email address, so there is no handle to
+		// mention yet.
+		c.sendError(msg.ID, "Mentions are not supported")
+		return
+	default:
+		c.sendError(msg.ID, "channel must be global, timeline, author or notifications")
+		return
+	}
+
+	lastID := uint64(0)
+	if msg.LastEventID != "" {
+		// An ID this server didn't hand out is treated as too old.
+		lastID, _ = strconv.ParseUint(msg.LastEventID, 10, 64)
+		lastID = max(lastID, 1)
+	}
+
+	c.mu.Lock()
+	if _, ok := c.subs[msg.ID]; ok {
+		c.mu.Unlock()
+		c.sendError(msg.ID, "Subscription id is already in use")
+		return
+	}
+	if len(c.subs) >= wsMaxSubscriptions {
+		c.mu.Unlock()
+		c.sendError(msg.ID, "Too many subscriptions")
+		return
+	}
+	sub, missed, complete := c.cfg.hub.Subscribe(lastID, allows)
+	c.subs[msg.ID] = sub
+	c.mu.Unlock()
+
+	go c.forward(msg.ID, sub, missed, complete)
+}
+
+// forward sends a subscription's events to the client. Like the SSE
+// stream, a subscription that can't keep up is dropped; the client can
+// subscribe again with the last event ID it saw.
+func (c *wsConn) forward(id string, sub *stream.Subscription, missed []stream.Event, complete bool) {
+	c.send(wsServerMessage{Type: "subscribed", ID: id, Reset: !complete})
+	for _, event := range missed {
+		c.sendEvent(id, event)
+	}
+
+	for event := range sub.C {
+		c.sendEvent(id, event)
+	}
+
+	c.mu.Lock()
+	dropped := c.subs[id] == sub
+	if dropped {
+		delete(c.subs, id)
+	}
+	c.mu.Unlock()
+	if dropped {
+		c.send(wsServerMessage{Type: "dropped", ID: id})
+	}
+}
+
+func (c *wsConn) allowsNotification(event stream.Event) bool {
+	return event.Type == stream.TypeFollow &&
+		event.RecipientID == c.userID &&
+		!c.filter.viewer.Blocks[event.AuthorID]
+}
+
+// keepAlive pings the client and enforces the token's expiry.
+func (c *wsConn) keepAlive(expiresAt time.Time) {
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	expiry := time.NewTimer(time.Until(expiresAt.Add(-wsReauthNotice)))
+	defer expiry.Stop()
+	warned := false
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case expiresAt = <-c.reauthed:
+			warned = false
+			expiry.Reset(time.Until(expiresAt.Add(-wsReauthNotice)))
+		case <-expiry.C:
+			if !warned {
+				warned = true
+				c.send(wsServerMessage{Type: "reauth", ExpiresAt: expiresAt})
+				expiry.Reset(time.Until(expiresAt))
+				continue
+			}
+			c.conn.Close(websocket.StatusPolicyViolation, "token expired")
+			c.cancel()
+			return
+		case <-ping.C:
+			ctx, cancel := context.WithTimeout(c.ctx, wsWriteTimeout)
+			err := c.conn.Ping(ctx)
+			cancel()
+			if err != nil {
+				c.cancel()
+				return
+			}
+		}
+	}
+}
+
+func (c *wsConn) sendEvent(id string, event stream.Event) {
+	c.send(wsServerMessage{
+		Type:    "event",
+		ID:      id,
+		EventID: strconv.FormatUint(event.ID, 10),
+		Event:   event.Type,
+		Data:    event.Data,
+	})
+}
+
+func (c *wsConn) sendError(id, msg string) {
+	c.send(wsServerMessage{Type: "error", ID: id, Error: msg})
+}
+
+// send writes a message, closing the connection if the client doesn't take
+// it in time. Writes are safe to make from several goroutines.
+func (c *wsConn) send(msg wsServerMessage) {
+	ctx, cancel := context.WithTimeout(c.ctx, wsWriteTimeout)
+	defer cancel()
+	err := wsjson.Write(ctx, c.conn, msg)
+	if err != nil {
+		c.cancel()
+	}
+}
+
+func (c *wsConn) closeSubscriptions() {
+	c.mu.Lock()
+	subs := c.subs
+	c.subs = map[string]*stream.Subscription{}
+	c.mu.Unlock()
+	for _, sub := range subs {
+		sub.Close()
+	}
+}